@@ -8,6 +8,7 @@ import (
 	"log"
 	"net/http"
 	"net/rpc"
+	"strings"
 	"time"
 
 	"github.com/danilobml/broker/cmd/api/event"
@@ -32,15 +33,50 @@ type AuthPayload struct {
 }
 
 type LogPayload struct {
-	Name string `json:"name"`
-	Data string `json:"data"`
+	Name     string `json:"name"`
+	Data     string `json:"data"`
+	Severity string `json:"severity,omitempty"`
+	Service  string `json:"service,omitempty"`
 }
 
 type MailPayload struct {
-	From    string `json:"from"`
-	To      string `json:"to"`
-	Subject string `json:"subject"`
-	Message string `json:"message"`
+	From     string            `json:"from"`
+	To       AddressList       `json:"to"`
+	CC       AddressList       `json:"cc,omitempty"`
+	BCC      AddressList       `json:"bcc,omitempty"`
+	ReplyTo  string            `json:"reply_to,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Subject  string            `json:"subject"`
+	Message  string            `json:"message"`
+	Template string            `json:"template,omitempty"`
+	Data     map[string]any    `json:"data,omitempty"`
+}
+
+// AddressList accepts a list of addresses or a single, possibly comma
+// separated, string and is passed on to mail-service as a list.
+type AddressList []string
+
+func (l *AddressList) UnmarshalJSON(b []byte) error {
+	var list []string
+	if err := json.Unmarshal(b, &list); err == nil {
+		*l = list
+		return nil
+	}
+
+	var single string
+	err := json.Unmarshal(b, &single)
+	if err != nil {
+		return errors.New("addresses must be a string or a list of strings")
+	}
+
+	*l = nil
+	for _, address := range strings.Split(single, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			*l = append(*l, address)
+		}
+	}
+
+	return nil
 }
 
 func (app *Config) Broker(w http.ResponseWriter, r *http.Request) {
@@ -240,8 +276,10 @@ func (app *Config) pushToQueue(name, msg string) error {
 }
 
 type RPCPayload struct {
-	Name string
-	Data string
+	Name     string
+	Data     string
+	Severity string
+	Service  string
 }
 
 func (app *Config) logEventViaRpc(w http.ResponseWriter, l LogPayload) {
@@ -251,8 +289,10 @@ func (app *Config) logEventViaRpc(w http.ResponseWriter, l LogPayload) {
 	}
 
 	rpcPayload := RPCPayload{
-		Name: l.Name,
-		Data: l.Data,
+		Name:     l.Name,
+		Data:     l.Data,
+		Severity: l.Severity,
+		Service:  l.Service,
 	}
 
 	var result string
@@ -0,0 +1,321 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/danilobml/logger-service/data"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	maxHistory = 500
+
+	notifyWorkers = 2
+	notifyQueue   = 100
+	saveDelay     = time.Second
+)
+
+var ErrRuleNotFound = errors.New("alert rule not found")
+
+type Firing struct {
+	ID         string        `json:"id"`
+	RuleID     string        `json:"rule_id"`
+	RuleName   string        `json:"rule_name"`
+	FiredAt    time.Time     `json:"fired_at"`
+	Count      int           `json:"count"`
+	Entry      data.LogEntry `json:"entry"`
+	Recipients []string      `json:"recipients"`
+	Notified   bool          `json:"notified"`
+	Error      string        `json:"error,omitempty"`
+}
+
+type Notifier interface {
+	Notify(rule Rule, firing Firing) error
+}
+
+type ruleState struct {
+	rule      Rule
+	hits      []time.Time
+	lastFired time.Time
+}
+
+type state struct {
+	Rules   []Rule   `json:"rules"`
+	History []Firing `json:"history"`
+}
+
+type notification struct {
+	rule   Rule
+	firing Firing
+}
+
+// Engine keeps the alert rules and their recent matches in memory. When a
+// path is given, rules and fired alerts are persisted there as JSON; rule
+// changes are written straight away, fired alerts shortly after, off the
+// ingestion path. Notifications go through a bounded queue and are dropped
+// when it is full.
+type Engine struct {
+	mu       sync.Mutex
+	rules    map[string]*ruleState
+	history  []Firing
+	path     string
+	notifier Notifier
+
+	saveMu        sync.Mutex
+	saveScheduled chan struct{}
+	notifications chan notification
+}
+
+func NewEngine(path string, notifier Notifier) (*Engine, error) {
+	e := &Engine{
+		rules:         map[string]*ruleState{},
+		path:          path,
+		notifier:      notifier,
+		saveScheduled: make(chan struct{}, 1),
+		notifications: make(chan notification, notifyQueue),
+	}
+
+	if path == "" {
+		return e, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return e, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var saved state
+	err = json.Unmarshal(content, &saved)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range saved.Rules {
+		rule.applyDefaults()
+		e.rules[rule.ID] = &ruleState{rule: rule}
+	}
+	e.history = saved.History
+
+	return e, nil
+}
+
+// Evaluate checks a freshly stored entry against every enabled rule.
+func (e *Engine) Evaluate(entry data.LogEntry) {
+	now := time.Now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, rs := range e.rules {
+		if !rs.rule.Enabled || !rs.rule.Matches(entry) {
+			continue
+		}
+
+		window := time.Duration(rs.rule.Window)
+		hits := rs.hits[:0]
+		for _, hit := range rs.hits {
+			if now.Sub(hit) < window {
+				hits = append(hits, hit)
+			}
+		}
+		rs.hits = append(hits, now)
+
+		if len(rs.hits) < rs.rule.Threshold {
+			continue
+		}
+		if !rs.lastFired.IsZero() && now.Sub(rs.lastFired) < rs.rule.cooldown() {
+			continue
+		}
+
+		firing := Firing{
+			ID:         primitive.NewObjectID().Hex(),
+			RuleID:     rs.rule.ID,
+			RuleName:   rs.rule.Name,
+			FiredAt:    now,
+			Count:      len(rs.hits),
+			Entry:      entry,
+			Recipients: rs.rule.Recipients,
+		}
+
+		rs.lastFired = now
+		rs.hits = nil
+
+		select {
+		case e.notifications <- notification{rule: rs.rule, firing: firing}:
+		default:
+			firing.Error = "notification queue is full"
+		}
+
+		e.history = append(e.history, firing)
+		if len(e.history) > maxHistory {
+			e.history = e.history[len(e.history)-maxHistory:]
+		}
+		e.scheduleSave()
+	}
+}
+
+// Run sends queued notifications and writes scheduled saves.
+func (e *Engine) Run() {
+	for i := 0; i < notifyWorkers; i++ {
+		go func() {
+			for n := range e.notifications {
+				e.notify(n.rule, n.firing)
+			}
+		}()
+	}
+
+	for range e.saveScheduled {
+		time.Sleep(saveDelay)
+		e.save()
+	}
+}
+
+func (e *Engine) notify(rule Rule, firing Firing) {
+	err := e.notifier.Notify(rule, firing)
+	if err != nil {
+		log.Printf("error sending alert %q: %s", rule.Name, err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i := range e.history {
+		if e.history[i].ID == firing.ID {
+			e.history[i].Notified = err == nil
+			if err != nil {
+				e.history[i].Error = err.Error()
+			}
+			break
+		}
+	}
+	e.scheduleSave()
+}
+
+func (e *Engine) Rules() []Rule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	rules := make([]Rule, 0, len(e.rules))
+	for _, rs := range e.rules {
+		rules = append(rules, rs.rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].ID < rules[j].ID
+	})
+
+	return rules
+}
+
+func (e *Engine) Rule(id string) (Rule, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	rs, ok := e.rules[id]
+	if !ok {
+		return Rule{}, ErrRuleNotFound
+	}
+
+	return rs.rule, nil
+}
+
+// SaveRule creates the rule when it has no id and replaces it otherwise.
+func (e *Engine) SaveRule(rule Rule) (Rule, error) {
+	err := rule.Validate()
+	if err != nil {
+		return Rule{}, err
+	}
+
+	e.mu.Lock()
+	if rule.ID == "" {
+		rule.ID = primitive.NewObjectID().Hex()
+		e.rules[rule.ID] = &ruleState{rule: rule}
+	} else {
+		rs, ok := e.rules[rule.ID]
+		if !ok {
+			e.mu.Unlock()
+			return Rule{}, ErrRuleNotFound
+		}
+		rs.rule = rule
+		rs.hits = nil
+	}
+	e.mu.Unlock()
+
+	e.save()
+
+	return rule, nil
+}
+
+func (e *Engine) DeleteRule(id string) error {
+	e.mu.Lock()
+	if _, ok := e.rules[id]; !ok {
+		e.mu.Unlock()
+		return ErrRuleNotFound
+	}
+	delete(e.rules, id)
+	e.mu.Unlock()
+
+	e.save()
+
+	return nil
+}
+
+func (e *Engine) History() []Firing {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	history := make([]Firing, len(e.history))
+	for i := range e.history {
+		history[len(e.history)-1-i] = e.history[i]
+	}
+
+	return history
+}
+
+// scheduleSave asks Run to save shortly; callers hold e.mu.
+func (e *Engine) scheduleSave() {
+	select {
+	case e.saveScheduled <- struct{}{}:
+	default:
+	}
+}
+
+// save writes a snapshot of the rules and history. Saves are serialised,
+// so a snapshot never overwrites a newer one; callers don't hold e.mu.
+func (e *Engine) save() {
+	if e.path == "" {
+		return
+	}
+
+	e.saveMu.Lock()
+	defer e.saveMu.Unlock()
+
+	e.mu.Lock()
+	saved := state{History: append([]Firing(nil), e.history...)}
+	for _, rs := range e.rules {
+		saved.Rules = append(saved.Rules, rs.rule)
+	}
+	e.mu.Unlock()
+
+	content, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		log.Println("error saving alert rules: ", err)
+		return
+	}
+
+	err = os.WriteFile(e.path+".tmp", content, 0644)
+	if err == nil {
+		err = os.Rename(e.path+".tmp", e.path)
+	}
+	if err != nil {
+		log.Println("error saving alert rules: ", err)
+	}
+}
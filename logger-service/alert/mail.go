@@ -0,0 +1,93 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// MailNotifier sends alerts through mail-service's /send endpoint.
+type MailNotifier struct {
+	URL               string
+	From              string
+	DefaultRecipients []string
+	Client            *http.Client
+}
+
+func NewMailNotifier(url, from string, recipients []string) *MailNotifier {
+	return &MailNotifier{
+		URL:               url,
+		From:              from,
+		DefaultRecipients: recipients,
+		Client:            &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (m *MailNotifier) Notify(rule Rule, firing Firing) error {
+	recipients := rule.Recipients
+	if len(recipients) == 0 {
+		recipients = m.DefaultRecipients
+	}
+	if len(recipients) == 0 {
+		return errors.New("no recipients configured")
+	}
+
+	subject := fmt.Sprintf("[alert] %s", rule.Name)
+	message := fmt.Sprintf(
+		"Alert %q fired at %s after %d matching entries.\n\nLast entry:\nname: %s\nseverity: %s\nservice: %s\ndata: %s",
+		rule.Name,
+		firing.FiredAt.Format(time.RFC3339),
+		firing.Count,
+		firing.Entry.Name,
+		firing.Entry.Severity,
+		firing.Entry.Service,
+		firing.Entry.Data,
+	)
+
+	var errs []error
+	for _, to := range recipients {
+		err := m.send(to, subject, message)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", to, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (m *MailNotifier) send(to, subject, message string) error {
+	payload := struct {
+		From    string `json:"from,omitempty"`
+		To      string `json:"to"`
+		Subject string `json:"subject"`
+		Message string `json:"message"`
+	}{
+		From:    m.From,
+		To:      to,
+		Subject: subject,
+		Message: message,
+	}
+
+	jsonData, _ := json.Marshal(payload)
+
+	request, err := http.NewRequest("POST", m.URL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := m.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusAccepted && response.StatusCode != http.StatusOK {
+		return fmt.Errorf("mail-service responded with %s", response.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/danilobml/logger-service/data"
+	"github.com/danilobml/logger-service/duration"
+)
+
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := duration.Parse(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+
+	return nil
+}
+
+// DefaultCooldown applies to rules that leave cooldown out, so a flood of
+// matching entries doesn't become a flood of mail; "0s" turns it off.
+const DefaultCooldown = 5 * time.Minute
+
+// Rule fires once Threshold matching entries arrive within Window.
+// A threshold of 1 (the default) fires on every matching entry.
+type Rule struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	LogName    string    `json:"log_name,omitempty"`
+	Severity   string    `json:"severity,omitempty"`
+	Service    string    `json:"service,omitempty"`
+	Contains   string    `json:"contains,omitempty"`
+	Threshold  int       `json:"threshold"`
+	Window     Duration  `json:"window"`
+	Cooldown   *Duration `json:"cooldown"`
+	Recipients []string  `json:"recipients,omitempty"`
+	Enabled    bool      `json:"enabled"`
+}
+
+func (r *Rule) Validate() error {
+	if r.Name == "" {
+		return errors.New("rule name is required")
+	}
+	if r.LogName == "" && r.Severity == "" && r.Service == "" && r.Contains == "" {
+		return errors.New("rule needs at least one of log_name, severity, service or contains")
+	}
+	if r.Threshold < 0 || r.Window < 0 || r.Cooldown != nil && *r.Cooldown < 0 {
+		return errors.New("threshold, window and cooldown cannot be negative")
+	}
+	if r.Threshold > 1 && r.Window == 0 {
+		return fmt.Errorf("a threshold of %d needs a window", r.Threshold)
+	}
+
+	r.applyDefaults()
+	r.Severity = strings.ToUpper(r.Severity)
+
+	return nil
+}
+
+func (r *Rule) applyDefaults() {
+	if r.Threshold == 0 {
+		r.Threshold = 1
+	}
+	if r.Cooldown == nil {
+		cooldown := Duration(DefaultCooldown)
+		r.Cooldown = &cooldown
+	}
+}
+
+func (r Rule) cooldown() time.Duration {
+	if r.Cooldown == nil {
+		return DefaultCooldown
+	}
+
+	return time.Duration(*r.Cooldown)
+}
+
+func (r Rule) Matches(entry data.LogEntry) bool {
+	if r.LogName != "" && entry.Name != r.LogName {
+		return false
+	}
+	if r.Severity != "" && entry.Severity != r.Severity {
+		return false
+	}
+	if r.Service != "" && entry.Service != r.Service {
+		return false
+	}
+	if r.Contains != "" && !strings.Contains(entry.Data, r.Contains) {
+		return false
+	}
+
+	return true
+}
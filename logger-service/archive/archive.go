@@ -0,0 +1,461 @@
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danilobml/logger-service/data"
+)
+
+const (
+	manifestFile = "manifest.json"
+	dayLayout    = "2006-01-02"
+
+	// writeDelay leaves entries written in the last moments to the next
+	// pass, so inserts still in flight when a pass starts aren't missed.
+	writeDelay = time.Minute
+)
+
+type Partition struct {
+	Day        string    `json:"day"`
+	Name       string    `json:"name"`
+	File       string    `json:"file"`
+	Entries    int       `json:"entries"`
+	SHA256     string    `json:"sha256"`
+	ArchivedAt time.Time `json:"archived_at"`
+}
+
+// Manifest lists the archived partitions. Every entry created before
+// Cutoff and last written before Watermark is in one of them.
+type Manifest struct {
+	Cutoff     time.Time   `json:"cutoff"`
+	Watermark  time.Time   `json:"watermark"`
+	Partitions []Partition `json:"partitions"`
+}
+
+type Archiver struct {
+	Store data.LogStore
+	Dir   string
+
+	mu        sync.Mutex
+	cutoff    time.Time
+	watermark time.Time
+}
+
+type partitionWriter struct {
+	partition Partition
+	file      *os.File
+	digest    hash.Hash
+	gzip      *gzip.Writer
+	buf       *bufio.Writer
+	path      string
+}
+
+func New(store data.LogStore, dir string) (*Archiver, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Archiver{Store: store, Dir: dir, cutoff: manifest.Cutoff, watermark: manifest.Watermark}, nil
+}
+
+// Archived reports how far the archive reaches: entries created before
+// cutoff and last written before watermark are archived. Both are zero
+// until the first pass.
+func (a *Archiver) Archived() (cutoff, watermark time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.cutoff, a.watermark
+}
+
+// Run archives complete UTC days, one day at a time. Entries that reach the
+// store late for a day that is already archived, from spool replay, past
+// timestamps or imports, go into another partition for that day.
+func (a *Archiver) Run(now time.Time) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	manifest, err := ReadManifest(a.Dir)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := now.UTC().Truncate(24 * time.Hour)
+	if cutoff.Before(manifest.Cutoff) {
+		cutoff = manifest.Cutoff
+	}
+	watermark := now.Add(-writeDelay)
+	if watermark.Before(manifest.Watermark) {
+		watermark = manifest.Watermark
+	}
+
+	// entries written since the last pass, and entries written before it
+	// that were too recent to archive then
+	filters := []data.Filter{{
+		Until:        cutoff,
+		UpdatedSince: manifest.Watermark,
+		UpdatedUntil: watermark,
+	}}
+	if !manifest.Watermark.IsZero() && manifest.Cutoff.Before(cutoff) {
+		filters = append(filters, data.Filter{
+			Since:        manifest.Cutoff,
+			Until:        cutoff,
+			UpdatedUntil: manifest.Watermark,
+		})
+	}
+
+	days, err := a.pendingDays(filters)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	var partitions []Partition
+	for _, day := range days {
+		n, written, err := a.archiveDay(day, filters, manifest)
+		if err != nil {
+			return 0, err
+		}
+		count += n
+		partitions = append(partitions, written...)
+		manifest.Partitions = append(manifest.Partitions, written...)
+	}
+
+	manifest.Cutoff = cutoff
+	manifest.Watermark = watermark
+	err = writeManifest(a.Dir, manifest)
+	if err != nil {
+		return 0, err
+	}
+	a.cutoff, a.watermark = cutoff, watermark
+
+	if count > 0 {
+		log.Printf("archive: wrote %d entries in %d partitions to %s", count, len(partitions), a.Dir)
+	}
+
+	return count, nil
+}
+
+func (a *Archiver) pendingDays(filters []data.Filter) ([]time.Time, error) {
+	seen := map[time.Time]bool{}
+	for _, filter := range filters {
+		err := a.Store.Each(filter, func(entry *data.LogEntry) error {
+			seen[entry.CreatedAt.UTC().Truncate(24*time.Hour)] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	days := make([]time.Time, 0, len(seen))
+	for day := range seen {
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Before(days[j])
+	})
+
+	return days, nil
+}
+
+// archiveDay writes one new partition per name for the pending entries of
+// a day. Files of a pass that fails are not in the manifest and get
+// overwritten by the next one.
+func (a *Archiver) archiveDay(day time.Time, filters []data.Filter, manifest Manifest) (int, []Partition, error) {
+	writers := map[string]*partitionWriter{}
+	defer func() {
+		for _, w := range writers {
+			w.abort()
+		}
+	}()
+
+	count := 0
+	for _, filter := range filters {
+		if filter.Since.Before(day) {
+			filter.Since = day
+		}
+		if next := day.Add(24 * time.Hour); next.Before(filter.Until) {
+			filter.Until = next
+		}
+
+		err := a.Store.Each(filter, func(entry *data.LogEntry) error {
+			w, ok := writers[entry.Name]
+			if !ok {
+				var err error
+				w, err = a.newPartitionWriter(day.Format(dayLayout), entry.Name, manifest)
+				if err != nil {
+					return err
+				}
+				writers[entry.Name] = w
+			}
+
+			count++
+			return w.write(entry)
+		})
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	var partitions []Partition
+	for name, w := range writers {
+		partition, err := w.close()
+		delete(writers, name)
+		if err != nil {
+			return 0, nil, err
+		}
+		partitions = append(partitions, partition)
+	}
+
+	return count, partitions, nil
+}
+
+// Import restores archived partitions into the store. Empty day or name
+// match every partition; entries whose id already exists are skipped.
+func (a *Archiver) Import(day, name string) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	manifest, err := ReadManifest(a.Dir)
+	if err != nil {
+		return 0, err
+	}
+
+	imported := 0
+	for _, partition := range manifest.Partitions {
+		if day != "" && partition.Day != day {
+			continue
+		}
+		if name != "" && partition.Name != name {
+			continue
+		}
+
+		n, err := a.importPartition(partition)
+		if err != nil {
+			return imported, fmt.Errorf("importing %s: %w", partition.File, err)
+		}
+		imported += n
+	}
+
+	return imported, nil
+}
+
+func (a *Archiver) importPartition(partition Partition) (int, error) {
+	file, err := os.Open(filepath.Join(a.Dir, partition.File))
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	imported := 0
+	decoder := json.NewDecoder(reader)
+	for {
+		var entry data.LogEntry
+		err := decoder.Decode(&entry)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return imported, err
+		}
+
+		if entry.ID != "" {
+			existing, err := a.Store.FindOne(entry.ID)
+			if err == nil && existing != nil {
+				continue
+			}
+		}
+
+		err = a.Store.Insert(context.Background(), entry)
+		if err != nil {
+			return imported, err
+		}
+		imported++
+	}
+
+	return imported, nil
+}
+
+func ReadManifest(dir string) (Manifest, error) {
+	var manifest Manifest
+
+	content, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return manifest, nil
+	}
+	if err != nil {
+		return manifest, err
+	}
+
+	err = json.Unmarshal(content, &manifest)
+
+	return manifest, err
+}
+
+func writeManifest(dir string, manifest Manifest) error {
+	sort.SliceStable(manifest.Partitions, func(i, j int) bool {
+		if manifest.Partitions[i].Day != manifest.Partitions[j].Day {
+			return manifest.Partitions[i].Day < manifest.Partitions[j].Day
+		}
+		if manifest.Partitions[i].Name != manifest.Partitions[j].Name {
+			return manifest.Partitions[i].Name < manifest.Partitions[j].Name
+		}
+		return manifest.Partitions[i].ArchivedAt.Before(manifest.Partitions[j].ArchivedAt)
+	})
+
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, manifestFile)
+	err = os.WriteFile(path+".tmp", content, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// fileName escapes every byte outside [A-Za-z0-9_-], so distinct names
+// get distinct files and the part suffix can't be mistaken for a name.
+func fileName(name string) string {
+	if name == "" {
+		return "%unnamed"
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+
+	return b.String()
+}
+
+// partitionFile picks the first part number whose file isn't already in
+// the manifest: name.ndjson.gz, then name.2.ndjson.gz and so on.
+func partitionFile(day, name string, manifest Manifest) string {
+	used := map[string]bool{}
+	for _, p := range manifest.Partitions {
+		used[p.File] = true
+	}
+
+	relPath := filepath.Join(day, fileName(name)+".ndjson.gz")
+	for part := 2; used[relPath]; part++ {
+		relPath = filepath.Join(day, fmt.Sprintf("%s.%d.ndjson.gz", fileName(name), part))
+	}
+
+	return relPath
+}
+
+func (a *Archiver) newPartitionWriter(day, name string, manifest Manifest) (*partitionWriter, error) {
+	relPath := partitionFile(day, name, manifest)
+	path := filepath.Join(a.Dir, relPath)
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(file, digest))
+
+	return &partitionWriter{
+		partition: Partition{Day: day, Name: name, File: relPath},
+		file:      file,
+		digest:    digest,
+		gzip:      gz,
+		buf:       bufio.NewWriter(gz),
+		path:      path,
+	}, nil
+}
+
+func (w *partitionWriter) write(entry *data.LogEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.buf.Write(append(line, '\n'))
+	if err != nil {
+		return err
+	}
+
+	w.partition.Entries++
+
+	return nil
+}
+
+func (w *partitionWriter) close() (Partition, error) {
+	err := w.buf.Flush()
+	if err != nil {
+		w.abort()
+		return Partition{}, err
+	}
+
+	err = w.gzip.Close()
+	if err != nil {
+		w.abort()
+		return Partition{}, err
+	}
+
+	err = w.file.Close()
+	if err != nil {
+		os.Remove(w.path + ".tmp")
+		return Partition{}, err
+	}
+
+	err = os.Rename(w.path+".tmp", w.path)
+	if err != nil {
+		return Partition{}, err
+	}
+
+	w.partition.SHA256 = hex.EncodeToString(w.digest.Sum(nil))
+	w.partition.ArchivedAt = time.Now()
+
+	return w.partition, nil
+}
+
+func (w *partitionWriter) abort() {
+	w.file.Close()
+	os.Remove(w.path + ".tmp")
+}
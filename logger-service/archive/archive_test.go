@@ -0,0 +1,232 @@
+package archive
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/danilobml/logger-service/data"
+)
+
+var day1 = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func insert(t *testing.T, store data.LogStore, entries ...data.LogEntry) {
+	t.Helper()
+
+	for _, entry := range entries {
+		err := store.Insert(context.Background(), entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func storedData(t *testing.T, store data.LogStore) []string {
+	t.Helper()
+
+	entries, err := store.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out []string
+	for _, entry := range entries {
+		out = append(out, entry.Name+":"+entry.Data)
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+func partitionFiles(manifest Manifest) []string {
+	var files []string
+	for _, p := range manifest.Partitions {
+		files = append(files, p.File)
+	}
+
+	return files
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestRunImportRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store := data.NewMemoryStore()
+
+	insert(t, store,
+		data.LogEntry{Name: "auth", Data: "login", Attributes: map[string]string{"user": "bob"}, CreatedAt: day1.Add(time.Hour)},
+		data.LogEntry{Name: "auth", Data: "logout", CreatedAt: day1.Add(2 * time.Hour)},
+		data.LogEntry{Name: "a/b", Data: "odd name", CreatedAt: day1.Add(3 * time.Hour)},
+		data.LogEntry{Name: "auth", Data: "login again", CreatedAt: day1.Add(25 * time.Hour)},
+	)
+
+	archiver, err := New(store, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// updated_at is the real clock, so every pass runs as if the write delay
+	// had just passed since the previous step
+	steps := []struct {
+		name  string
+		setup func()
+		count int
+		files []string
+	}{
+		{
+			name:  "first pass",
+			count: 4,
+			files: []string{"2024-01-01/a%2Fb.ndjson.gz", "2024-01-01/auth.ndjson.gz", "2024-01-02/auth.ndjson.gz"},
+		},
+		{
+			name:  "nothing new",
+			count: 0,
+			files: []string{"2024-01-01/a%2Fb.ndjson.gz", "2024-01-01/auth.ndjson.gz", "2024-01-02/auth.ndjson.gz"},
+		},
+		{
+			name: "late entry for an archived day",
+			setup: func() {
+				insert(t, store, data.LogEntry{Name: "auth", Data: "late", CreatedAt: day1.Add(4 * time.Hour)})
+			},
+			count: 1,
+			files: []string{"2024-01-01/a%2Fb.ndjson.gz", "2024-01-01/auth.ndjson.gz", "2024-01-01/auth.2.ndjson.gz", "2024-01-02/auth.ndjson.gz"},
+		},
+		{
+			name: "repeat counts are not archived again",
+			setup: func() {
+				entries, _ := data.Find(store, data.Filter{Name: "auth"})
+				store.UpdateRepeats([]data.RepeatUpdate{{ID: entries[0].ID, Repeat: 3, FirstSeen: day1, LastSeen: day1}})
+			},
+			count: 0,
+			files: []string{"2024-01-01/a%2Fb.ndjson.gz", "2024-01-01/auth.ndjson.gz", "2024-01-01/auth.2.ndjson.gz", "2024-01-02/auth.ndjson.gz"},
+		},
+	}
+
+	for _, step := range steps {
+		if step.setup != nil {
+			step.setup()
+		}
+
+		count, err := archiver.Run(time.Now().Add(writeDelay))
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if count != step.count {
+			t.Errorf("%s: archived %d, want %d", step.name, count, step.count)
+		}
+
+		manifest, err := ReadManifest(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if files := partitionFiles(manifest); !equal(files, step.files) {
+			t.Errorf("%s: partitions %q, want %q", step.name, files, step.files)
+		}
+	}
+
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range manifest.Partitions {
+		content, err := os.ReadFile(filepath.Join(dir, p.File))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(content)
+		if hex.EncodeToString(sum[:]) != p.SHA256 {
+			t.Errorf("%s: checksum does not match the manifest", p.File)
+		}
+	}
+
+	imports := []struct {
+		day, name string
+		want      []string
+	}{
+		{day: "2024-01-02", want: []string{"auth:login again"}},
+		{name: "a/b", want: []string{"a/b:odd name", "auth:login again"}},
+		{want: storedData(t, store)},
+	}
+
+	restored := data.NewMemoryStore()
+	importer, err := New(restored, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range imports {
+		_, err := importer.Import(step.day, step.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := storedData(t, restored); !equal(got, step.want) {
+			t.Errorf("import %q %q: store holds %q, want %q", step.day, step.name, got, step.want)
+		}
+	}
+
+	n, err := importer.Import("", "")
+	if err != nil || n != 0 {
+		t.Errorf("importing again restored %d entries (%v), want none", n, err)
+	}
+
+	entries, _ := data.Find(restored, data.Filter{Name: "auth", Until: day1.Add(90 * time.Minute)})
+	if len(entries) != 1 || entries[0].Attributes["user"] != "bob" {
+		t.Errorf("restored entry lost its attributes: %+v", entries)
+	}
+}
+
+func TestRunLeavesRecentWrites(t *testing.T) {
+	dir := t.TempDir()
+	store := data.NewMemoryStore()
+	insert(t, store, data.LogEntry{Name: "auth", Data: "just now", CreatedAt: day1})
+
+	archiver, err := New(store, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := archiver.Run(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("archived %d entries written within the write delay", count)
+	}
+
+	cutoff, watermark := archiver.Archived()
+	if cutoff.IsZero() || watermark.IsZero() {
+		t.Errorf("archived reach not recorded: %s, %s", cutoff, watermark)
+	}
+}
+
+func TestFileName(t *testing.T) {
+	tests := map[string]string{
+		"auth":      "auth",
+		"api-v2_io": "api-v2_io",
+		"a/b":       "a%2Fb",
+		"a.2":       "a%2E2",
+		"":          "%unnamed",
+		"%":         "%25",
+	}
+
+	for name, want := range tests {
+		if got := fileName(name); got != want {
+			t.Errorf("fileName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
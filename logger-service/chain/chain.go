@@ -0,0 +1,243 @@
+package chain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/danilobml/logger-service/data"
+)
+
+type head struct {
+	seq  int64
+	hash string
+}
+
+// Chain links every entry of the selected log names to the previous entry
+// of the same name through a sha256 hash, so that edits and deletions in
+// the middle of a stream can be detected.
+type Chain struct {
+	mu    sync.Mutex
+	names map[string]bool
+	heads map[string]head
+}
+
+func New(names []string) *Chain {
+	c := &Chain{
+		names: map[string]bool{},
+		heads: map[string]head{},
+	}
+
+	for _, name := range names {
+		c.names[name] = true
+	}
+
+	return c
+}
+
+func (c *Chain) Chained(name string) bool {
+	return c.names[name]
+}
+
+func (c *Chain) Names() []string {
+	var names []string
+	for name := range c.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Load restores the head of every chained stream from the store.
+func (c *Chain) Load(store data.LogStore) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name := range c.names {
+		var last head
+		err := store.Each(data.Filter{Name: name}, func(entry *data.LogEntry) error {
+			if entry.ChainSeq > last.seq {
+				last = head{seq: entry.ChainSeq, hash: entry.ChainHash}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		c.heads[name] = last
+	}
+
+	return nil
+}
+
+// Append seals the entry onto its stream and stores it through insert. The
+// head only advances when insert succeeds.
+func (c *Chain) Append(entry *data.LogEntry, insert func(entry data.LogEntry) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev := c.heads[entry.Name]
+
+	// stores keep millisecond precision, so seal what will be read back
+	entry.CreatedAt = entry.CreatedAt.UTC().Truncate(time.Millisecond)
+	entry.ChainSeq = prev.seq + 1
+	entry.PrevHash = prev.hash
+	entry.ChainHash = Hash(*entry)
+
+	err := insert(*entry)
+	if err != nil {
+		return err
+	}
+
+	c.heads[entry.Name] = head{seq: entry.ChainSeq, hash: entry.ChainHash}
+
+	return nil
+}
+
+// Hash covers every stored field except the id, updated_at and the hash itself.
+func Hash(entry data.LogEntry) string {
+	canonical := struct {
+		Seq        int64             `json:"seq"`
+		PrevHash   string            `json:"prev_hash"`
+		Name       string            `json:"name"`
+		Data       string            `json:"data"`
+		Severity   string            `json:"severity"`
+		Service    string            `json:"service"`
+		Attributes map[string]string `json:"attributes"`
+		TraceID    string            `json:"trace_id"`
+		SpanID     string            `json:"span_id"`
+		CreatedAt  string            `json:"created_at"`
+	}{
+		Seq:        entry.ChainSeq,
+		PrevHash:   entry.PrevHash,
+		Name:       entry.Name,
+		Data:       entry.Data,
+		Severity:   entry.Severity,
+		Service:    entry.Service,
+		Attributes: entry.Attributes,
+		TraceID:    entry.TraceID,
+		SpanID:     entry.SpanID,
+		CreatedAt:  entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	if len(canonical.Attributes) == 0 {
+		canonical.Attributes = nil
+	}
+
+	// encoding/json sorts map keys, which keeps the encoding stable
+	content, _ := json.Marshal(canonical)
+	sum := sha256.Sum256(content)
+
+	return hex.EncodeToString(sum[:])
+}
+
+type Break struct {
+	ID       string `json:"id,omitempty"`
+	Seq      int64  `json:"seq"`
+	Reason   string `json:"reason"`
+	Expected string `json:"expected,omitempty"`
+	Found    string `json:"found,omitempty"`
+}
+
+type Report struct {
+	Name     string `json:"name"`
+	Entries  int    `json:"entries"`
+	FirstSeq int64  `json:"first_seq"`
+	LastSeq  int64  `json:"last_seq"`
+	Valid    bool   `json:"valid"`
+	Broken   *Break `json:"broken,omitempty"`
+}
+
+// Verify walks a stream in sequence order and reports the first broken link.
+// Entries removed from the start of the stream (e.g. by retention) are not
+// treated as a break; gaps anywhere after the first entry are, and so is an
+// entry stored under the name without being chained once the chain began.
+// Unchained entries from before that are the name's earlier history.
+func Verify(store data.LogStore, name string) (Report, error) {
+	report := Report{Name: name}
+
+	var entries []data.LogEntry
+	var unchained *data.LogEntry
+	err := store.Each(data.Filter{Name: name}, func(entry *data.LogEntry) error {
+		if entry.ChainSeq > 0 {
+			entries = append(entries, *entry)
+		} else if unchained == nil || entry.CreatedAt.After(unchained.CreatedAt) {
+			found := *entry
+			unchained = &found
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ChainSeq < entries[j].ChainSeq
+	})
+
+	report.Entries = len(entries)
+	report.Valid = true
+	if len(entries) > 0 {
+		report.FirstSeq = entries[0].ChainSeq
+		report.LastSeq = entries[len(entries)-1].ChainSeq
+	}
+
+	if unchained != nil && len(entries) > 0 && !unchained.CreatedAt.Before(chainStart(entries)) {
+		report.fail(*unchained, "entry is not part of the chain", "", "")
+		return report, nil
+	}
+
+	for i, entry := range entries {
+		if i > 0 {
+			prev := entries[i-1]
+
+			if entry.ChainSeq == prev.ChainSeq {
+				report.fail(entry, "duplicate sequence number", "", "")
+				break
+			}
+			if entry.ChainSeq != prev.ChainSeq+1 {
+				report.fail(entry, fmt.Sprintf("missing entries %d to %d", prev.ChainSeq+1, entry.ChainSeq-1), "", "")
+				break
+			}
+			if entry.PrevHash != prev.ChainHash {
+				report.fail(entry, "previous hash does not match", prev.ChainHash, entry.PrevHash)
+				break
+			}
+		}
+
+		if expected := Hash(entry); expected != entry.ChainHash {
+			report.fail(entry, "entry content does not match its hash", expected, entry.ChainHash)
+			break
+		}
+	}
+
+	return report, nil
+}
+
+// chainStart is when the earliest remaining chained entry was created.
+func chainStart(entries []data.LogEntry) time.Time {
+	start := entries[0].CreatedAt
+	for _, entry := range entries[1:] {
+		if entry.CreatedAt.Before(start) {
+			start = entry.CreatedAt
+		}
+	}
+
+	return start
+}
+
+func (r *Report) fail(entry data.LogEntry, reason, expected, found string) {
+	r.Valid = false
+	r.Broken = &Break{
+		ID:       entry.ID,
+		Seq:      entry.ChainSeq,
+		Reason:   reason,
+		Expected: expected,
+		Found:    found,
+	}
+}
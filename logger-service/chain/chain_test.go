@@ -0,0 +1,216 @@
+package chain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/danilobml/logger-service/data"
+)
+
+var start = time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// chainedStore holds four chained "audit" entries a minute apart.
+func chainedStore(t *testing.T) (*data.MemoryStore, []data.LogEntry) {
+	t.Helper()
+
+	store := data.NewMemoryStore()
+	c := New([]string{"audit"})
+	insert := func(entry data.LogEntry) error {
+		return store.Insert(context.Background(), entry)
+	}
+
+	var entries []data.LogEntry
+	for i, text := range []string{"login alice", "grant admin to bob", "login bob", "logout alice"} {
+		entry := data.LogEntry{
+			ID:        string(rune('a' + i)),
+			Name:      "audit",
+			Severity:  "INFO",
+			Data:      text,
+			CreatedAt: start.Add(time.Duration(i) * time.Minute),
+		}
+		err := c.Append(&entry, insert)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return store, entries
+}
+
+func at(minutes int) data.Filter {
+	return data.Filter{
+		Since: start.Add(time.Duration(minutes) * time.Minute),
+		Until: start.Add(time.Duration(minutes)*time.Minute + time.Second),
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   func(t *testing.T, store *data.MemoryStore, entries []data.LogEntry)
+		valid    bool
+		reason   string
+		brokenID string
+		firstSeq int64
+	}{
+		{
+			name:     "intact",
+			valid:    true,
+			firstSeq: 1,
+		},
+		{
+			name: "tampered data",
+			change: func(t *testing.T, store *data.MemoryStore, entries []data.LogEntry) {
+				tampered := entries[1]
+				tampered.Data = "grant admin to mallory"
+				if err := store.Update(tampered); err != nil {
+					t.Fatal(err)
+				}
+			},
+			reason:   "entry content does not match its hash",
+			brokenID: "b",
+			firstSeq: 1,
+		},
+		{
+			name: "deleted in the middle",
+			change: func(t *testing.T, store *data.MemoryStore, entries []data.LogEntry) {
+				store.Delete(at(2))
+			},
+			reason:   "missing entries 3 to 3",
+			brokenID: "d",
+			firstSeq: 1,
+		},
+		{
+			name: "deleted from the start by retention",
+			change: func(t *testing.T, store *data.MemoryStore, entries []data.LogEntry) {
+				store.Delete(data.Filter{Until: start.Add(2 * time.Minute)})
+			},
+			valid:    true,
+			firstSeq: 3,
+		},
+		{
+			name: "duplicated entry",
+			change: func(t *testing.T, store *data.MemoryStore, entries []data.LogEntry) {
+				copied := entries[2]
+				copied.ID = "copy"
+				store.Insert(context.Background(), copied)
+			},
+			reason:   "duplicate sequence number",
+			firstSeq: 1,
+		},
+		{
+			name: "forged link",
+			change: func(t *testing.T, store *data.MemoryStore, entries []data.LogEntry) {
+				forged := data.LogEntry{
+					ID:        "forged",
+					Name:      "audit",
+					Severity:  "INFO",
+					Data:      "nothing to see",
+					CreatedAt: start.Add(10 * time.Minute),
+					ChainSeq:  5,
+					PrevHash:  entries[2].ChainHash,
+				}
+				forged.ChainHash = Hash(forged)
+				store.Insert(context.Background(), forged)
+			},
+			reason:   "previous hash does not match",
+			brokenID: "forged",
+			firstSeq: 1,
+		},
+		{
+			name: "history from before chaining",
+			change: func(t *testing.T, store *data.MemoryStore, entries []data.LogEntry) {
+				store.Insert(context.Background(), data.LogEntry{ID: "old", Name: "audit", Data: "old", CreatedAt: start.Add(-time.Hour)})
+			},
+			valid:    true,
+			firstSeq: 1,
+		},
+		{
+			name: "unchained entry after the chain began",
+			change: func(t *testing.T, store *data.MemoryStore, entries []data.LogEntry) {
+				store.Insert(context.Background(), data.LogEntry{ID: "sneaky", Name: "audit", Data: "sneaky", CreatedAt: start.Add(90 * time.Second)})
+			},
+			reason:   "entry is not part of the chain",
+			brokenID: "sneaky",
+			firstSeq: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, entries := chainedStore(t)
+			if tt.change != nil {
+				tt.change(t, store, entries)
+			}
+
+			report, err := Verify(store, "audit")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if report.Valid != tt.valid {
+				t.Fatalf("valid = %v, want %v (broken: %+v)", report.Valid, tt.valid, report.Broken)
+			}
+			if report.FirstSeq != tt.firstSeq {
+				t.Errorf("first seq = %d, want %d", report.FirstSeq, tt.firstSeq)
+			}
+			if tt.valid {
+				return
+			}
+			if report.Broken.Reason != tt.reason {
+				t.Errorf("reason = %q, want %q", report.Broken.Reason, tt.reason)
+			}
+			if tt.brokenID != "" && report.Broken.ID != tt.brokenID {
+				t.Errorf("broken id = %q, want %q", report.Broken.ID, tt.brokenID)
+			}
+		})
+	}
+}
+
+func TestLoadContinuesChain(t *testing.T) {
+	store, entries := chainedStore(t)
+
+	c := New([]string{"audit"})
+	err := c.Load(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := data.LogEntry{ID: "e", Name: "audit", Severity: "INFO", Data: "login carol", CreatedAt: start.Add(5 * time.Minute)}
+	err = c.Append(&next, func(entry data.LogEntry) error {
+		return store.Insert(context.Background(), entry)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if next.ChainSeq != 5 || next.PrevHash != entries[3].ChainHash {
+		t.Errorf("appended seq %d after %q, want 5 after %q", next.ChainSeq, next.PrevHash, entries[3].ChainHash)
+	}
+
+	report, err := Verify(store, "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || report.Entries != 5 || report.LastSeq != 5 {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestAppendKeepsHeadOnFailure(t *testing.T) {
+	c := New([]string{"audit"})
+
+	failed := data.LogEntry{Name: "audit", Data: "lost", CreatedAt: start}
+	err := c.Append(&failed, func(data.LogEntry) error { return context.DeadlineExceeded })
+	if err == nil {
+		t.Fatal("append succeeded")
+	}
+
+	entry := data.LogEntry{Name: "audit", Data: "kept", CreatedAt: start}
+	c.Append(&entry, func(data.LogEntry) error { return nil })
+	if entry.ChainSeq != 1 || entry.PrevHash != "" {
+		t.Errorf("seq %d after %q, want the first link", entry.ChainSeq, entry.PrevHash)
+	}
+}
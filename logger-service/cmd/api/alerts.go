@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+
+	goweb "github.com/danilobml/go-webtoolkit"
+	"github.com/danilobml/logger-service/alert"
+	"github.com/go-chi/chi/v5"
+)
+
+const defaultMailServiceUrl = "http://mail-service/send"
+
+func newAlertEngine() (*alert.Engine, error) {
+	mailUrl := os.Getenv("MAIL_SERVICE_URL")
+	if mailUrl == "" {
+		mailUrl = defaultMailServiceUrl
+	}
+
+	recipients := splitList(os.Getenv("LOG_ALERT_RECIPIENTS"))
+
+	notifier := alert.NewMailNotifier(mailUrl, os.Getenv("LOG_ALERT_FROM"), recipients)
+
+	return alert.NewEngine(os.Getenv("LOG_ALERTS_FILE"), notifier)
+}
+
+func (app *Config) GetAlertRules(w http.ResponseWriter, r *http.Request) {
+	payload := goweb.JsonResponse{
+		Error: false,
+		Data:  app.Alerts.Rules(),
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
+
+func (app *Config) GetAlertRule(w http.ResponseWriter, r *http.Request) {
+	rule, err := app.Alerts.Rule(chi.URLParam(r, "id"))
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusNotFound)
+		return
+	}
+
+	payload := goweb.JsonResponse{
+		Error: false,
+		Data:  rule,
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
+
+func (app *Config) CreateAlertRule(w http.ResponseWriter, r *http.Request) {
+	var rule alert.Rule
+
+	err := tools.ReadJSON(w, r, &rule)
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	rule.ID = ""
+	rule, err = app.Alerts.SaveRule(rule)
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	payload := goweb.JsonResponse{
+		Error:   false,
+		Message: "alert rule created",
+		Data:    rule,
+	}
+
+	tools.WriteJSON(w, http.StatusCreated, payload)
+}
+
+func (app *Config) UpdateAlertRule(w http.ResponseWriter, r *http.Request) {
+	var rule alert.Rule
+
+	err := tools.ReadJSON(w, r, &rule)
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	rule.ID = chi.URLParam(r, "id")
+	rule, err = app.Alerts.SaveRule(rule)
+	if errors.Is(err, alert.ErrRuleNotFound) {
+		tools.ErrorJSON(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	payload := goweb.JsonResponse{
+		Error:   false,
+		Message: "alert rule updated",
+		Data:    rule,
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
+
+func (app *Config) DeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	err := app.Alerts.DeleteRule(chi.URLParam(r, "id"))
+	if err != nil {
+		log.Println(err)
+		tools.ErrorJSON(w, err, http.StatusNotFound)
+		return
+	}
+
+	payload := goweb.JsonResponse{
+		Error:   false,
+		Message: "alert rule deleted",
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
+
+func (app *Config) GetAlertHistory(w http.ResponseWriter, r *http.Request) {
+	payload := goweb.JsonResponse{
+		Error: false,
+		Data:  app.Alerts.History(),
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
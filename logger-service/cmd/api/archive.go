@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/danilobml/logger-service/archive"
+	"github.com/danilobml/logger-service/data"
+)
+
+func newArchiver(store data.LogStore) (*archive.Archiver, error) {
+	dir := os.Getenv("LOG_ARCHIVE_DIR")
+	if dir == "" {
+		return nil, nil
+	}
+
+	return archive.New(store, dir)
+}
+
+func (app *Config) runArchive(interval time.Duration) {
+	if app.Archiver == nil {
+		return
+	}
+
+	log.Printf("Archiving log entries to %s every %s", app.Archiver.Dir, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		app.archive()
+		<-ticker.C
+	}
+}
+
+func (app *Config) archive() error {
+	if app.Archiver == nil {
+		return nil
+	}
+
+	_, err := app.Archiver.Run(time.Now())
+	if err != nil {
+		log.Println("error archiving log entries: ", err)
+		return err
+	}
+
+	return nil
+}
+
+// importArchive implements `loggerApp import [-day YYYY-MM-DD] [-name NAME]`,
+// restoring archived partitions from LOG_ARCHIVE_DIR into the configured store.
+func (app *Config) importArchive(args []string) error {
+	if app.Archiver == nil {
+		return errors.New("LOG_ARCHIVE_DIR is not set")
+	}
+
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	day := flags.String("day", "", "only import partitions for this day (YYYY-MM-DD)")
+	name := flags.String("name", "", "only import partitions for this log name")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	imported, err := app.Archiver.Import(*day, *name)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Imported %d log entries from %s", imported, app.Archiver.Dir)
+
+	return nil
+}
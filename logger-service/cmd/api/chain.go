@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	goweb "github.com/danilobml/go-webtoolkit"
+	"github.com/danilobml/logger-service/chain"
+)
+
+// newChain reads the log names to hash-chain from LOG_CHAIN_NAMES (e.g. "auth,admin").
+func (app *Config) newChain() (*chain.Chain, error) {
+	names := splitList(os.Getenv("LOG_CHAIN_NAMES"))
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	c := chain.New(names)
+
+	err := c.Load(app.Models.LogEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (app *Config) verifyChains(names []string) ([]chain.Report, error) {
+	if len(names) == 0 && app.Chain != nil {
+		names = app.Chain.Names()
+	}
+	if len(names) == 0 {
+		return nil, errors.New("no chained log names configured")
+	}
+
+	var reports []chain.Report
+	for _, name := range names {
+		report, err := chain.Verify(app.Models.LogEntry, name)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+func (app *Config) VerifyChain(w http.ResponseWriter, r *http.Request) {
+	var names []string
+	if name := r.URL.Query().Get("name"); name != "" {
+		names = append(names, name)
+	}
+
+	reports, err := app.verifyChains(names)
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	payload := goweb.JsonResponse{
+		Error: false,
+		Data:  reports,
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
+
+// verifyChainCommand implements `loggerApp verify-chain [-name NAME]`, printing
+// one report per stream and exiting non-zero when a link is broken.
+func (app *Config) verifyChainCommand(args []string) error {
+	flags := flag.NewFlagSet("verify-chain", flag.ContinueOnError)
+	name := flags.String("name", "", "only verify this log name")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	if *name != "" {
+		names = append(names, *name)
+	}
+
+	reports, err := app.verifyChains(names)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	broken := 0
+	for _, report := range reports {
+		encoder.Encode(report)
+		if !report.Valid {
+			broken++
+		}
+	}
+
+	if broken > 0 {
+		return fmt.Errorf("%d chain(s) broken", broken)
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/danilobml/logger-service/data"
+)
+
+var csvHeader = []string{"id", "name", "severity", "service", "data", "attributes", "created_at", "updated_at"}
+
+// parseFilter reads the query parameters shared by the query endpoints:
+// name, severity, service, since and until (RFC 3339).
+func parseFilter(r *http.Request) (data.Filter, error) {
+	query := r.URL.Query()
+
+	filter := data.Filter{
+		Name:     query.Get("name"),
+		Severity: query.Get("severity"),
+		Service:  query.Get("service"),
+	}
+
+	var err error
+	if since := query.Get("since"); since != "" {
+		filter.Since, err = time.Parse(time.RFC3339, since)
+		if err != nil {
+			return filter, fmt.Errorf("invalid since: %w", err)
+		}
+	}
+	if until := query.Get("until"); until != "" {
+		filter.Until, err = time.Parse(time.RFC3339, until)
+		if err != nil {
+			return filter, fmt.Errorf("invalid until: %w", err)
+		}
+	}
+
+	return filter, nil
+}
+
+func (app *Config) ExportEntries(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseFilter(r)
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "ndjson"
+	}
+
+	var write func(entry *data.LogEntry) error
+	var finish func() error
+
+	switch format {
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="logs.ndjson"`)
+
+		encoder := json.NewEncoder(w)
+		write = func(entry *data.LogEntry) error {
+			return encoder.Encode(entry)
+		}
+		finish = func() error { return nil }
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="logs.csv"`)
+
+		writer := csv.NewWriter(w)
+		write = func(entry *data.LogEntry) error {
+			var attributes []byte
+			if len(entry.Attributes) > 0 {
+				attributes, _ = json.Marshal(entry.Attributes)
+			}
+
+			return writer.Write([]string{
+				csvCell(entry.ID),
+				csvCell(entry.Name),
+				csvCell(entry.Severity),
+				csvCell(entry.Service),
+				csvCell(entry.Data),
+				csvCell(string(attributes)),
+				entry.CreatedAt.Format(time.RFC3339Nano),
+				entry.UpdatedAt.Format(time.RFC3339Nano),
+			})
+		}
+		finish = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+
+		err = writer.Write(csvHeader)
+		if err != nil {
+			log.Println("error exporting log entries: ", err)
+			return
+		}
+	default:
+		tools.ErrorJSON(w, fmt.Errorf("unsupported format: %s", format), http.StatusBadRequest)
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	written := 0
+
+	err = app.Models.LogEntry.Each(filter, func(entry *data.LogEntry) error {
+		err := write(entry)
+		if err != nil {
+			return err
+		}
+
+		written++
+		if flusher != nil && written%500 == 0 {
+			finish()
+			flusher.Flush()
+		}
+
+		return r.Context().Err()
+	})
+	if err != nil {
+		log.Println("error exporting log entries: ", err)
+		return
+	}
+
+	err = finish()
+	if err != nil {
+		log.Println("error exporting log entries: ", err)
+	}
+}
+
+// csvCell prefixes cells that a spreadsheet would read as a formula.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
@@ -5,15 +5,21 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"time"
 
 	"github.com/danilobml/logger-service/data"
 	"github.com/danilobml/logger-service/logs"
+	"github.com/danilobml/logger-service/otlp"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
 type LoggerService struct{
 	logs.UnimplementedLoggerServiceServer
-	Models data.Models
+	App *Config
 }
 
 func (l *LoggerService) WriteLog(ctx context.Context, req *logs.LogRequest) (*logs.LogResponse, error) {
@@ -24,7 +30,7 @@ func (l *LoggerService) WriteLog(ctx context.Context, req *logs.LogRequest) (*lo
 		Data: input.Data,
 	}
 
-	err := l.Models.LogEntry.Insert(logEntry)
+	err := l.App.ingest(ctx, logEntry)
 	if err != nil {
 		res := &logs.LogResponse{Result: "failed"}
 		return res, err
@@ -40,9 +46,21 @@ func (app *Config) gRPCListen() {
 		log.Fatal("Failed to listen to gRPC", err)
 	}
 
-	s := grpc.NewServer()
+	timeout := envDuration("LOG_GRPC_TIMEOUT", defaultGRPCTimeout)
 
-	logs.RegisterLoggerServiceServer(s, &LoggerService{Models: app.Models})
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(app.unaryInterceptor(timeout)),
+		grpc.ChainStreamInterceptor(app.streamInterceptor(timeout)),
+	)
+
+	logs.RegisterLoggerServiceServer(s, &LoggerService{App: app})
+	collogspb.RegisterLogsServiceServer(s, &otlp.LogsServer{Handler: app.ingest})
+
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(s, healthServer)
+	go app.watchHealth(healthServer)
+
+	reflection.Register(s)
 
 	log.Printf("gRPC server listening on port %v", gRpcPort)
 
@@ -50,3 +68,22 @@ func (app *Config) gRPCListen() {
 		log.Fatal("Failed to listen to gRPC", err)
 	}
 }
+
+// watchHealth keeps the gRPC health status in line with the log store, for
+// the whole server and for each of its services.
+func (app *Config) watchHealth(healthServer *health.Server) {
+	services := []string{"", logs.LoggerService_ServiceDesc.ServiceName, collogspb.LogsService_ServiceDesc.ServiceName}
+
+	for {
+		status := healthpb.HealthCheckResponse_SERVING
+		if err := app.Models.LogEntry.Ping(); err != nil {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+
+		for _, service := range services {
+			healthServer.SetServingStatus(service, status)
+		}
+
+		time.Sleep(healthCheckInterval)
+	}
+}
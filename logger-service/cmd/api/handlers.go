@@ -11,24 +11,33 @@ import (
 var tools goweb.Tools
 
 type JSONPayload struct {
-	Name string
-	Data string
+	Name     string
+	Data     string
+	Severity string
+	Service  string
 }
 
 func (app *Config) WriteLog(w http.ResponseWriter, r *http.Request) {
 	var requestPayload JSONPayload
 
-	tools.ReadJSON(w, r, &requestPayload)
+	err := tools.ReadJSON(w, r, &requestPayload)
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
 
 	event := data.LogEntry{
-		Name: requestPayload.Name,
-		Data: requestPayload.Data,
+		Name:     requestPayload.Name,
+		Data:     requestPayload.Data,
+		Severity: requestPayload.Severity,
+		Service:  requestPayload.Service,
 	}
 
-	err := app.Models.LogEntry.Insert(event)
+	err = app.ingest(r.Context(), event)
 	if err != nil {
 		log.Println(err)
-		tools.ErrorJSON(w, err, http.StatusInternalServerError)
+		tools.ErrorJSON(w, err, http.StatusServiceUnavailable)
+		return
 	}
 
 	payload := goweb.JsonResponse{
@@ -40,11 +49,17 @@ func (app *Config) WriteLog(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app *Config) GetAllEntries(w http.ResponseWriter, r *http.Request) {
-	logEntry := data.LogEntry{}
-	entries, err := logEntry.All()
+	filter, err := parseFilter(r)
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	entries, err := data.Find(app.Models.LogEntry, filter)
 	if err != nil {
 		log.Println(err)
 		tools.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
 	}
 
 	payload := goweb.JsonResponse{
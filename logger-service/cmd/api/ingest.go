@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/danilobml/logger-service/data"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ingest is the single path every log entry takes into the store,
+// whether it arrives over HTTP, RPC or gRPC; ctx bounds the store write.
+func (app *Config) ingest(ctx context.Context, entry data.LogEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	entry.Severity = strings.ToUpper(entry.Severity)
+
+	if app.Redactor != nil {
+		app.Redactor.Apply(&entry)
+	}
+
+	chained := app.Chain != nil && app.Chain.Chained(entry.Name)
+
+	// alert rules still see entries that are sampled out or collapsed, so
+	// thresholds count what was actually sent; chained streams keep every entry
+	if app.Sampler != nil && !chained && !app.Sampler.Keep(entry.Name) {
+		app.evaluateAlerts(entry)
+		return nil
+	}
+
+	// a fixed id lets replays from the spool be recognised as duplicates
+	if entry.ID == "" {
+		entry.ID = primitive.NewObjectID().Hex()
+	}
+
+	// chained entries are never collapsed, their hashes cover every field
+	if app.Deduper != nil && !chained && app.Deduper.Seen(&entry) {
+		app.evaluateAlerts(entry)
+		return nil
+	}
+
+	var err error
+	if chained {
+		err = app.Chain.Append(&entry, func(entry data.LogEntry) error {
+			return app.insert(ctx, entry)
+		})
+	} else {
+		err = app.insert(ctx, entry)
+	}
+	if err != nil {
+		if app.Deduper != nil {
+			app.Deduper.Forget(&entry)
+		}
+		return err
+	}
+
+	app.evaluateAlerts(entry)
+
+	return nil
+}
+
+func (app *Config) evaluateAlerts(entry data.LogEntry) {
+	if app.Alerts != nil {
+		app.Alerts.Evaluate(entry)
+	}
+}
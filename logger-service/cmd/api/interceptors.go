@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	goweb "github.com/danilobml/go-webtoolkit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultGRPCTimeout  = 10 * time.Second
+	healthCheckInterval = 5 * time.Second
+)
+
+type MethodStats struct {
+	Method    string           `json:"method"`
+	Calls     int64            `json:"calls"`
+	Errors    int64            `json:"errors"`
+	Codes     map[string]int64 `json:"codes"`
+	TotalMs   float64          `json:"total_ms"`
+	AverageMs float64          `json:"average_ms"`
+	MaxMs     float64          `json:"max_ms"`
+}
+
+// grpcMetrics counts calls, status codes and latency per full method name.
+type grpcMetrics struct {
+	mu      sync.Mutex
+	methods map[string]*MethodStats
+}
+
+func newGRPCMetrics() *grpcMetrics {
+	return &grpcMetrics{methods: map[string]*MethodStats{}}
+}
+
+func (m *grpcMetrics) record(method string, elapsed time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.methods[method]
+	if !ok {
+		stats = &MethodStats{Method: method, Codes: map[string]int64{}}
+		m.methods[method] = stats
+	}
+
+	ms := float64(elapsed.Microseconds()) / 1000
+	stats.Calls++
+	stats.TotalMs += ms
+	if ms > stats.MaxMs {
+		stats.MaxMs = ms
+	}
+	if err != nil {
+		stats.Errors++
+	}
+	stats.Codes[status.Code(err).String()]++
+}
+
+func (m *grpcMetrics) snapshot() []MethodStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]MethodStats, 0, len(m.methods))
+	for _, stats := range m.methods {
+		s := *stats
+		s.Codes = make(map[string]int64, len(stats.Codes))
+		for code, n := range stats.Codes {
+			s.Codes[code] = n
+		}
+		s.AverageMs = s.TotalMs / float64(s.Calls)
+		out = append(out, s)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Method < out[j].Method
+	})
+
+	return out
+}
+
+// unaryInterceptor applies a default deadline when the caller sent none,
+// turns panics into Internal errors and logs and records every call.
+func (app *Config) unaryInterceptor(timeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		start := time.Now()
+
+		if _, ok := ctx.Deadline(); !ok && timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		defer func() {
+			if p := recover(); p != nil {
+				log.Printf("panic in %s: %v\n%s", info.FullMethod, p, debug.Stack())
+				err = status.Error(codes.Internal, "internal error")
+			}
+			app.finishCall(info.FullMethod, start, err)
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+func (app *Config) streamInterceptor(timeout time.Duration) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		start := time.Now()
+
+		ctx := stream.Context()
+		if _, ok := ctx.Deadline(); !ok && timeout > 0 && !info.IsServerStream {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+			stream = &deadlineStream{ServerStream: stream, ctx: ctx}
+		}
+
+		defer func() {
+			if p := recover(); p != nil {
+				log.Printf("panic in %s: %v\n%s", info.FullMethod, p, debug.Stack())
+				err = status.Error(codes.Internal, "internal error")
+			}
+			app.finishCall(info.FullMethod, start, err)
+		}()
+
+		return handler(srv, stream)
+	}
+}
+
+func (app *Config) finishCall(method string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	app.GRPCMetrics.record(method, elapsed, err)
+
+	if err != nil {
+		log.Printf("gRPC %s %s in %s: %s", method, status.Code(err), elapsed, err)
+		return
+	}
+	log.Printf("gRPC %s OK in %s", method, elapsed)
+}
+
+type deadlineStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *deadlineStream) Context() context.Context {
+	return s.ctx
+}
+
+func (app *Config) GetGRPCMetrics(w http.ResponseWriter, r *http.Request) {
+	payload := goweb.JsonResponse{
+		Error: false,
+		Data:  app.GRPCMetrics.snapshot(),
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
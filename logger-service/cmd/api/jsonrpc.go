@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"strings"
+)
+
+const maxJSONRPCBody = 1 << 20
+
+const (
+	jsonRPCParseError     = -32700
+	jsonRPCInvalidRequest = -32600
+	jsonRPCMethodNotFound = -32601
+	jsonRPCInvalidParams  = -32602
+	jsonRPCServerError    = -32000
+)
+
+type jsonRPCRequest struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type jsonRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type jsonRPCResponse struct {
+	Version string          `json:"jsonrpc"`
+	Result  any             `json:"result"`
+	Error   *jsonRPCError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// MarshalJSON writes result on success, even when it is empty or zero, and
+// leaves it out on error, as JSON-RPC 2.0 requires exactly one of the two.
+func (r jsonRPCResponse) MarshalJSON() ([]byte, error) {
+	if r.Error != nil {
+		return json.Marshal(struct {
+			Version string          `json:"jsonrpc"`
+			Error   *jsonRPCError   `json:"error"`
+			ID      json.RawMessage `json:"id"`
+		}{r.Version, r.Error, r.ID})
+	}
+
+	type success jsonRPCResponse
+	return json.Marshal(success(r))
+}
+
+// jsonRPCListen serves the registered RPC methods with net/rpc's JSON codec,
+// for clients that cannot speak gob.
+func (app *Config) jsonRPCListen() error {
+	log.Println("JSON-RPC server listening on port: ", jsonRpcPort)
+
+	listen, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%s", jsonRpcPort))
+	if err != nil {
+		return err
+	}
+	defer listen.Close()
+
+	for {
+		conn, err := listen.Accept()
+		if err != nil {
+			continue
+		}
+		go jsonrpc.ServeConn(conn)
+	}
+}
+
+// JSONRPC handles JSON-RPC 2.0 calls and batches over HTTP, dispatching to
+// the same methods as the net/rpc server, e.g. "RPCServer.LogInfo".
+func (app *Config) JSONRPC(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxJSONRPCBody))
+	if err != nil {
+		writeJSONRPC(w, errorResponse(nil, jsonRPCParseError, err.Error()))
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		err := json.Unmarshal(body, &batch)
+		if err != nil {
+			writeJSONRPC(w, errorResponse(nil, jsonRPCParseError, err.Error()))
+			return
+		}
+		if len(batch) == 0 {
+			writeJSONRPC(w, errorResponse(nil, jsonRPCInvalidRequest, "empty batch"))
+			return
+		}
+
+		responses := []*jsonRPCResponse{}
+		for _, raw := range batch {
+			if resp := callJSONRPC(raw); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		writeJSONRPC(w, responses)
+		return
+	}
+
+	resp := callJSONRPC(body)
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSONRPC(w, resp)
+}
+
+// callJSONRPC runs a single call and returns nil for notifications.
+func callJSONRPC(raw json.RawMessage) *jsonRPCResponse {
+	var req jsonRPCRequest
+	err := json.Unmarshal(raw, &req)
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return errorResponse(nil, jsonRPCParseError, err.Error())
+		}
+		return errorResponse(nil, jsonRPCInvalidRequest, err.Error())
+	}
+
+	if req.Version != "2.0" || req.Method == "" {
+		return errorResponse(req.ID, jsonRPCInvalidRequest, "invalid request")
+	}
+
+	method := req.Method
+	if !strings.Contains(method, ".") {
+		method = "RPCServer." + method
+	}
+
+	codec := &singleCallCodec{method: method, params: req.Params}
+	rpc.ServeRequest(codec)
+
+	// only a missing id makes a notification; "id": null still gets a reply
+	if len(req.ID) == 0 {
+		return nil
+	}
+
+	switch {
+	case codec.paramsErr != nil:
+		return errorResponse(req.ID, jsonRPCInvalidParams, codec.paramsErr.Error())
+	case strings.HasPrefix(codec.err, "rpc: can't find"):
+		return errorResponse(req.ID, jsonRPCMethodNotFound, codec.err)
+	case codec.err != "":
+		return errorResponse(req.ID, jsonRPCServerError, codec.err)
+	}
+
+	return &jsonRPCResponse{Version: "2.0", Result: codec.result, ID: req.ID}
+}
+
+func errorResponse(id json.RawMessage, code int, message string) *jsonRPCResponse {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+
+	return &jsonRPCResponse{
+		Version: "2.0",
+		Error:   &jsonRPCError{Code: code, Message: message},
+		ID:      id,
+	}
+}
+
+func writeJSONRPC(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// singleCallCodec feeds one decoded call through net/rpc and captures the
+// reply, so HTTP calls reuse the registered methods unchanged.
+type singleCallCodec struct {
+	method string
+	params json.RawMessage
+	read   bool
+
+	paramsErr error
+	err       string
+	result    any
+}
+
+func (c *singleCallCodec) ReadRequestHeader(r *rpc.Request) error {
+	if c.read {
+		return io.EOF
+	}
+	c.read = true
+
+	r.ServiceMethod = c.method
+	r.Seq = 0
+
+	return nil
+}
+
+// ReadRequestBody accepts params as an object or, like net/rpc/jsonrpc, as
+// an array holding a single object.
+func (c *singleCallCodec) ReadRequestBody(body any) error {
+	if body == nil || len(c.params) == 0 {
+		return nil
+	}
+
+	params := bytes.TrimSpace(c.params)
+	if len(params) > 0 && params[0] == '[' {
+		var list []json.RawMessage
+		err := json.Unmarshal(params, &list)
+		if err == nil && len(list) != 1 {
+			err = errors.New("params array must hold exactly one element")
+		}
+		if err != nil {
+			c.paramsErr = err
+			return err
+		}
+		params = list[0]
+	}
+
+	err := json.Unmarshal(params, body)
+	if err != nil {
+		c.paramsErr = err
+	}
+
+	return err
+}
+
+func (c *singleCallCodec) WriteResponse(r *rpc.Response, body any) error {
+	c.err = r.Error
+	if r.Error == "" {
+		c.result = body
+	}
+
+	return nil
+}
+
+func (c *singleCallCodec) Close() error {
+	return nil
+}
@@ -7,55 +7,155 @@ import (
 	"net"
 	"net/http"
 	"net/rpc"
+	"os"
 	"time"
 
+	"github.com/danilobml/logger-service/alert"
+	"github.com/danilobml/logger-service/archive"
+	"github.com/danilobml/logger-service/chain"
 	"github.com/danilobml/logger-service/data"
+	"github.com/danilobml/logger-service/duration"
+	"github.com/danilobml/logger-service/redact"
+	"github.com/danilobml/logger-service/spool"
+	"github.com/danilobml/logger-service/throttle"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const (
-	webPort  = "80"
-	mongoUrl = "mongodb://mongo:27017"
-	rpcPort  = "5001"
-	gRpcPort = "50001"
+	webPort     = "80"
+	mongoUrl    = "mongodb://mongo:27017"
+	rpcPort     = "5001"
+	jsonRpcPort = "5002"
+	gRpcPort    = "50001"
+
+	defaultLogFilePath       = "./logs/logs.jsonl"
+	defaultRetentionInterval = time.Hour
+	defaultArchiveInterval   = time.Hour
 )
 
 var client *mongo.Client
 
 type Config struct {
-	Models data.Models
+	Models    data.Models
+	Retention []RetentionPolicy
+	Archiver  *archive.Archiver
+	Alerts    *alert.Engine
+	Redactor  *redact.Redactor
+	Chain     *chain.Chain
+	Spool     *spool.Spool
+	Sampler   *throttle.Sampler
+	Deduper   *throttle.Deduper
+
+	GRPCMetrics *grpcMetrics
 }
 
 func main() {
-	mongoClient, err := connectToMongo()
+	store, err := newLogStore(os.Getenv("LOG_STORE"))
 	if err != nil {
 		log.Panic(err)
 	}
 
-	client = mongoClient
-
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
 	defer cancel()
 
 	defer func() {
+		if client == nil {
+			return
+		}
 		if err = client.Disconnect(ctx); err != nil {
 			panic(err)
 		}
 	}()
 
+	retention, err := parseRetentionPolicies(os.Getenv("LOG_RETENTION"))
+	if err != nil {
+		log.Panic(err)
+	}
+
+	archiver, err := newArchiver(store)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	alerts, err := newAlertEngine()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	redactor, err := newRedactor()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	spooler, err := newSpool()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	sampler, err := newSampler()
+	if err != nil {
+		log.Panic(err)
+	}
+
 	app := Config{
-		Models: data.New(client),
+		Models:    data.New(store),
+		Retention: retention,
+		Archiver:  archiver,
+		Alerts:    alerts,
+		Redactor:  redactor,
+		Spool:     spooler,
+		Sampler:   sampler,
+
+		GRPCMetrics: newGRPCMetrics(),
 	}
 
-	err = rpc.Register(new(RPCServer))
+	app.Chain, err = app.newChain()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	app.Deduper = app.newDeduper()
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "import":
+			err = app.importArchive(os.Args[2:])
+		case "verify-chain":
+			err = app.verifyChainCommand(os.Args[2:])
+		default:
+			err = fmt.Errorf("unknown command: %s", os.Args[1])
+		}
+		if err != nil {
+			log.Panic(err)
+		}
+		return
+	}
+
+	err = rpc.Register(&RPCServer{App: &app})
 	if err != nil {
 		log.Panic("rpc server registration failed")
 	}
 	go app.rpcListen()
 
+	go app.jsonRPCListen()
+
 	go app.gRPCListen()
 
+	app.syslogListen()
+
+	go app.replaySpool(defaultSpoolInterval)
+
+	go app.Alerts.Run()
+
+	if app.Deduper != nil {
+		go app.Deduper.Run(defaultDedupFlushInterval)
+	}
+
+	go app.runArchive(envDuration("LOG_ARCHIVE_INTERVAL", defaultArchiveInterval))
+
+	go app.runRetention(envDuration("LOG_RETENTION_INTERVAL", defaultRetentionInterval))
+
 	app.serve()
 }
 
@@ -73,6 +173,50 @@ func (app *Config) serve() {
 	}
 }
 
+func newLogStore(storeType string) (data.LogStore, error) {
+	switch storeType {
+	case "", "mongo":
+		mongoClient, err := connectToMongo()
+		if err != nil {
+			return nil, err
+		}
+
+		client = mongoClient
+
+		return data.NewMongoStore(client), nil
+	case "file":
+		path := os.Getenv("LOG_FILE_PATH")
+		if path == "" {
+			path = defaultLogFilePath
+		}
+
+		log.Printf("Using file log store at %s", path)
+
+		return data.NewFileStore(path)
+	case "memory":
+		log.Println("Using in-memory log store")
+
+		return data.NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown LOG_STORE: %s", storeType)
+	}
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	d, err := duration.Parse(value)
+	if err != nil || d <= 0 {
+		log.Printf("invalid %s %q, using %s", key, value, fallback)
+		return fallback
+	}
+
+	return d
+}
+
 func connectToMongo() (*mongo.Client, error) {
 	clientOptions := options.Client().ApplyURI(mongoUrl)
 
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	goweb "github.com/danilobml/go-webtoolkit"
+	"github.com/danilobml/logger-service/redact"
+)
+
+const defaultRedactDetectors = "email,card,token"
+
+// newRedactor reads LOG_REDACT_DETECTORS (built-ins, "none" to disable),
+// LOG_REDACT_RULES (JSON list of {name, pattern, replacement}) and
+// LOG_REDACT_FIELDS (attribute keys masked entirely).
+func newRedactor() (*redact.Redactor, error) {
+	detectors := os.Getenv("LOG_REDACT_DETECTORS")
+	if detectors == "" {
+		detectors = defaultRedactDetectors
+	}
+
+	custom, err := redact.ParseCustomRules(os.Getenv("LOG_REDACT_RULES"))
+	if err != nil {
+		return nil, err
+	}
+
+	return redact.New(splitList(detectors), custom, splitList(os.Getenv("LOG_REDACT_FIELDS")))
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" && item != "none" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+func (app *Config) GetRedactionStats(w http.ResponseWriter, r *http.Request) {
+	payload := goweb.JsonResponse{
+		Error: false,
+		Data: map[string]any{
+			"rules":  app.Redactor.RuleNames(),
+			"counts": app.Redactor.Counts(),
+		},
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	goweb "github.com/danilobml/go-webtoolkit"
+	"github.com/danilobml/logger-service/data"
+	"github.com/danilobml/logger-service/duration"
+)
+
+type RetentionPolicy struct {
+	Name     string
+	Severity string
+	MaxAge   time.Duration
+}
+
+type retentionReport struct {
+	Name        string    `json:"name,omitempty"`
+	Severity    string    `json:"severity,omitempty"`
+	MaxAge      string    `json:"max_age"`
+	Cutoff      time.Time `json:"cutoff"`
+	WouldDelete int64     `json:"would_delete"`
+}
+
+// retentionFilter selects the entries a policy removes. With archiving on,
+// only entries the archive already holds are selected, so it is false
+// until the first archive pass.
+func (app *Config) retentionFilter(p RetentionPolicy, now time.Time) (data.Filter, bool) {
+	filter := data.Filter{
+		Name:     p.Name,
+		Severity: p.Severity,
+		Until:    now.Add(-p.MaxAge),
+	}
+
+	if app.Archiver != nil {
+		cutoff, watermark := app.Archiver.Archived()
+		if cutoff.IsZero() {
+			return filter, false
+		}
+		if filter.Until.After(cutoff) {
+			filter.Until = cutoff
+		}
+		filter.UpdatedUntil = watermark
+	}
+
+	return filter, true
+}
+
+// parseRetentionPolicies reads policies such as
+// [{"severity":"DEBUG","max_age":"7d"},{"name":"audit","max_age":"365d"}].
+// Every policy is applied on its own, so an entry is removed as soon as
+// any policy that matches it considers it expired.
+func parseRetentionPolicies(raw string) ([]RetentionPolicy, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var input []struct {
+		Name     string `json:"name"`
+		Severity string `json:"severity"`
+		MaxAge   string `json:"max_age"`
+	}
+
+	err := json.Unmarshal([]byte(raw), &input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid retention policies: %w", err)
+	}
+
+	var policies []RetentionPolicy
+	for _, p := range input {
+		maxAge, err := duration.Parse(p.MaxAge)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_age %q: %w", p.MaxAge, err)
+		}
+		if maxAge <= 0 {
+			return nil, fmt.Errorf("max_age must be positive: %q", p.MaxAge)
+		}
+
+		policies = append(policies, RetentionPolicy{
+			Name:     p.Name,
+			Severity: strings.ToUpper(p.Severity),
+			MaxAge:   maxAge,
+		})
+	}
+
+	return policies, nil
+}
+
+func (app *Config) runRetention(interval time.Duration) {
+	if len(app.Retention) == 0 {
+		return
+	}
+
+	log.Printf("Applying %d retention policies every %s", len(app.Retention), interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		app.applyRetention()
+		<-ticker.C
+	}
+}
+
+func (app *Config) applyRetention() {
+	err := app.archive()
+	if err != nil {
+		log.Println("skipping retention until archiving succeeds")
+		return
+	}
+
+	now := time.Now()
+
+	for _, policy := range app.Retention {
+		filter, ok := app.retentionFilter(policy, now)
+		if !ok {
+			continue
+		}
+
+		deleted, err := app.Models.LogEntry.Delete(filter)
+		if err != nil {
+			log.Println("error applying retention policy: ", err)
+			continue
+		}
+
+		if deleted > 0 {
+			log.Printf("retention: removed %d entries (name=%q severity=%q max_age=%s)", deleted, policy.Name, policy.Severity, policy.MaxAge)
+		}
+	}
+}
+
+func (app *Config) GetRetentionReport(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+
+	reports := []retentionReport{}
+	for _, policy := range app.Retention {
+		filter, ok := app.retentionFilter(policy, now)
+
+		var count int64
+		if ok {
+			var err error
+			count, err = app.Models.LogEntry.Count(filter)
+			if err != nil {
+				log.Println(err)
+				tools.ErrorJSON(w, err, http.StatusInternalServerError)
+				return
+			}
+		}
+
+		reports = append(reports, retentionReport{
+			Name:        policy.Name,
+			Severity:    policy.Severity,
+			MaxAge:      policy.MaxAge.String(),
+			Cutoff:      filter.Until,
+			WouldDelete: count,
+		})
+	}
+
+	payload := goweb.JsonResponse{
+		Error: false,
+		Data:  reports,
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"net/http"
 
+	"github.com/danilobml/logger-service/otlp"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
 )
@@ -19,8 +20,29 @@ func (app *Config) routes() http.Handler {
 		MaxAge:           300,
 	}))
 
+	mux.Get("/health", app.Health)
+	mux.Get("/metrics/grpc", app.GetGRPCMetrics)
+
 	mux.Post("/log", app.WriteLog)
+	mux.Post("/rpc", app.JSONRPC)
 	mux.Get("/log", app.GetAllEntries)
+	mux.Get("/log/export", app.ExportEntries)
+	mux.Get("/log/search", app.SearchEntries)
+	mux.Get("/log/throttle", app.GetThrottleStats)
+	mux.Get("/log/retention", app.GetRetentionReport)
+	mux.Get("/log/stats", app.GetStats)
+	mux.Get("/log/stats/top", app.GetTopStats)
+	mux.Get("/log/redactions", app.GetRedactionStats)
+	mux.Get("/log/chain/verify", app.VerifyChain)
+
+	mux.Method(http.MethodPost, "/v1/logs", &otlp.LogsServer{Handler: app.ingest})
+
+	mux.Get("/alerts/rules", app.GetAlertRules)
+	mux.Post("/alerts/rules", app.CreateAlertRule)
+	mux.Get("/alerts/rules/{id}", app.GetAlertRule)
+	mux.Put("/alerts/rules/{id}", app.UpdateAlertRule)
+	mux.Delete("/alerts/rules/{id}", app.DeleteAlertRule)
+	mux.Get("/alerts/history", app.GetAlertHistory)
 
 	return mux
 }
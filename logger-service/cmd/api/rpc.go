@@ -3,29 +3,30 @@ package main
 import (
 	"context"
 	"log"
-	"time"
 
 	"github.com/danilobml/logger-service/data"
 )
 
-type RPCServer struct{}
+type RPCServer struct {
+	App *Config
+}
 
 type RPCPayload struct {
-	Name string
-	Data string
+	Name     string
+	Data     string
+	Severity string
+	Service  string
 }
 
 func (r *RPCServer) LogInfo(payload RPCPayload, resp *string) error {
-
-	collection := client.Database("logs").Collection("logs")
-
-	_, err := collection.InsertOne(context.TODO(), data.LogEntry{
-		Name:      payload.Name,
-		Data:      payload.Data,
-		CreatedAt: time.Now(),
+	err := r.App.ingest(context.Background(), data.LogEntry{
+		Name:     payload.Name,
+		Data:     payload.Data,
+		Severity: payload.Severity,
+		Service:  payload.Service,
 	})
 	if err != nil {
-		log.Println("error logging to mongodb", err)
+		log.Println("error logging via rpc", err)
 		return err
 	}
 
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	goweb "github.com/danilobml/go-webtoolkit"
+	"github.com/danilobml/logger-service/data"
+)
+
+// SearchEntries runs a full-text query over entry data, e.g.
+// /log/search?q="payment failed" -retry&service=broker&limit=20
+func (app *Config) SearchEntries(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	query, err := data.ParseSearch(params.Get("q"))
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	query.Filter, err = parseFilter(r)
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit <= 0 {
+			tools.ErrorJSON(w, fmt.Errorf("invalid limit: %s", limit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	results, err := app.Models.LogEntry.Search(query)
+	if err != nil {
+		log.Println("error searching log entries: ", err)
+		tools.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if results == nil {
+		results = []data.SearchResult{}
+	}
+
+	payload := goweb.JsonResponse{
+		Error:   false,
+		Message: fmt.Sprintf("%d matching entries", len(results)),
+		Data:    results,
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
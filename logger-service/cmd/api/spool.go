@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	goweb "github.com/danilobml/go-webtoolkit"
+	"github.com/danilobml/logger-service/data"
+	"github.com/danilobml/logger-service/spool"
+)
+
+const (
+	defaultSpoolDir      = "./spool"
+	defaultSpoolMax      = 100000
+	defaultSpoolInterval = 5 * time.Second
+)
+
+// newSpool opens the on-disk spool in LOG_SPOOL_DIR ("off" disables it),
+// bounded to LOG_SPOOL_MAX entries.
+func newSpool() (*spool.Spool, error) {
+	dir := os.Getenv("LOG_SPOOL_DIR")
+	if dir == "off" {
+		return nil, nil
+	}
+	if dir == "" {
+		dir = defaultSpoolDir
+	}
+
+	max := defaultSpoolMax
+	if value := os.Getenv("LOG_SPOOL_MAX"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, err
+		}
+		max = n
+	}
+
+	return spool.Open(dir, max)
+}
+
+// insert writes to the store, falling back to the spool when the store
+// fails. While the spool holds entries new ones are queued behind them so
+// that replay keeps the original order.
+func (app *Config) insert(ctx context.Context, entry data.LogEntry) error {
+	if app.Spool == nil {
+		return app.Models.LogEntry.Insert(ctx, entry)
+	}
+
+	if app.Spool.Depth() == 0 {
+		err := app.Models.LogEntry.Insert(ctx, entry)
+		if err == nil {
+			return nil
+		}
+		log.Println("log store unavailable, spooling entry: ", err)
+	}
+
+	return app.Spool.Push(entry)
+}
+
+func (app *Config) replaySpool(interval time.Duration) {
+	if app.Spool == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if app.Spool.Depth() > 0 {
+			replayed, err := app.Spool.Drain(func(entry data.LogEntry) error {
+				err := app.Models.LogEntry.Insert(context.Background(), entry)
+				if errors.Is(err, data.ErrDuplicate) {
+					return nil
+				}
+				return err
+			})
+			if replayed > 0 {
+				log.Printf("replayed %d spooled log entries, %d left", replayed, app.Spool.Depth())
+			}
+			if err != nil {
+				log.Println("spool replay paused: ", err)
+			}
+		}
+
+		<-ticker.C
+	}
+}
+
+func (app *Config) Health(w http.ResponseWriter, r *http.Request) {
+	health := map[string]any{
+		"status": "ok",
+		"store":  "ok",
+	}
+
+	err := app.Models.LogEntry.Ping()
+	if err != nil {
+		health["status"] = "degraded"
+		health["store"] = err.Error()
+	}
+
+	if app.Spool != nil {
+		depth := app.Spool.Depth()
+		health["spool_depth"] = depth
+		if depth > 0 {
+			health["status"] = "degraded"
+		}
+	}
+
+	payload := goweb.JsonResponse{
+		Error: false,
+		Data:  health,
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	goweb "github.com/danilobml/go-webtoolkit"
+	"github.com/danilobml/logger-service/data"
+	"github.com/danilobml/logger-service/duration"
+)
+
+// GetStats counts entries grouped by the fields in group_by (name, severity,
+// service), optionally split into time buckets, e.g.
+// /log/stats?group_by=name&bucket=1h&since=2025-01-01T00:00:00Z
+func (app *Config) GetStats(w http.ResponseWriter, r *http.Request) {
+	query, err := parseAggregateQuery(r, "group_by")
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	app.writeAggregate(w, query)
+}
+
+// GetTopStats returns the most frequent values of a field, e.g. /log/stats/top?by=name&limit=10
+func (app *Config) GetTopStats(w http.ResponseWriter, r *http.Request) {
+	query, err := parseAggregateQuery(r, "by")
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if len(query.GroupBy) != 1 {
+		tools.ErrorJSON(w, fmt.Errorf("by must name a single field"), http.StatusBadRequest)
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = 10
+	}
+
+	app.writeAggregate(w, query)
+}
+
+func (app *Config) writeAggregate(w http.ResponseWriter, query data.AggregateQuery) {
+	rows, err := app.Models.LogEntry.Aggregate(query)
+	if err != nil {
+		log.Println(err)
+		tools.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if rows == nil {
+		rows = []data.AggregateRow{}
+	}
+
+	payload := goweb.JsonResponse{
+		Error: false,
+		Data:  rows,
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
+
+func parseAggregateQuery(r *http.Request, groupParam string) (data.AggregateQuery, error) {
+	var query data.AggregateQuery
+
+	filter, err := parseFilter(r)
+	if err != nil {
+		return query, err
+	}
+	query.Filter = filter
+
+	params := r.URL.Query()
+
+	groupBy := params.Get(groupParam)
+	if groupBy == "" {
+		groupBy = "name"
+	}
+	for _, field := range strings.Split(groupBy, ",") {
+		query.GroupBy = append(query.GroupBy, strings.TrimSpace(field))
+	}
+
+	if bucket := params.Get("bucket"); bucket != "" {
+		query.Bucket, err = duration.Parse(bucket)
+		if err != nil {
+			return query, fmt.Errorf("invalid bucket: %w", err)
+		}
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit < 0 {
+			return query, fmt.Errorf("invalid limit: %s", limit)
+		}
+	}
+
+	return query, query.Validate()
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/danilobml/logger-service/data"
+	"github.com/danilobml/logger-service/syslog"
+)
+
+// syslogListen starts the syslog listeners configured in LOG_SYSLOG_UDP and
+// LOG_SYSLOG_TCP (e.g. ":5514"); both are off by default.
+func (app *Config) syslogListen() {
+	server := &syslog.Server{Handler: func(entry data.LogEntry) error {
+		return app.ingest(context.Background(), entry)
+	}}
+
+	if addr := os.Getenv("LOG_SYSLOG_UDP"); addr != "" {
+		go func() {
+			err := server.ListenUDP(addr)
+			if err != nil {
+				log.Println("syslog udp listener stopped: ", err)
+			}
+		}()
+	}
+
+	if addr := os.Getenv("LOG_SYSLOG_TCP"); addr != "" {
+		go func() {
+			err := server.ListenTCP(addr)
+			if err != nil {
+				log.Println("syslog tcp listener stopped: ", err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	goweb "github.com/danilobml/go-webtoolkit"
+	"github.com/danilobml/logger-service/throttle"
+)
+
+const defaultDedupFlushInterval = 5 * time.Second
+
+// newSampler reads per-name sample rates from LOG_SAMPLE_RATES, e.g.
+// "debug-trace=0.1,*=0.5"; without it every entry is kept.
+func newSampler() (*throttle.Sampler, error) {
+	raw := os.Getenv("LOG_SAMPLE_RATES")
+	if raw == "" {
+		return nil, nil
+	}
+
+	rates, err := throttle.ParseRates(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return throttle.NewSampler(rates), nil
+}
+
+// newDeduper collapses identical entries within LOG_DEDUP_WINDOW, e.g. "1m".
+func (app *Config) newDeduper() *throttle.Deduper {
+	window := envDuration("LOG_DEDUP_WINDOW", 0)
+	if window <= 0 {
+		return nil
+	}
+
+	return throttle.NewDeduper(window, app.Models.LogEntry)
+}
+
+func (app *Config) GetThrottleStats(w http.ResponseWriter, r *http.Request) {
+	stats := map[string]any{}
+
+	if app.Sampler != nil {
+		stats["sampling"] = app.Sampler.Stats()
+	}
+	if app.Deduper != nil {
+		stats["dedup"] = app.Deduper.Stats()
+	}
+
+	payload := goweb.JsonResponse{
+		Error: false,
+		Data:  stats,
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
@@ -0,0 +1,133 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+var groupFields = map[string]bool{
+	"name":     true,
+	"severity": true,
+	"service":  true,
+}
+
+type AggregateQuery struct {
+	Filter  Filter
+	GroupBy []string
+	Bucket  time.Duration
+	Limit   int
+}
+
+type AggregateRow struct {
+	Bucket   *time.Time `bson:"bucket,omitempty" json:"bucket,omitempty"`
+	Name     string     `bson:"name,omitempty" json:"name,omitempty"`
+	Severity string     `bson:"severity,omitempty" json:"severity,omitempty"`
+	Service  string     `bson:"service,omitempty" json:"service,omitempty"`
+	Count    int64      `bson:"count" json:"count"`
+}
+
+func (q AggregateQuery) Validate() error {
+	for _, field := range q.GroupBy {
+		if !groupFields[field] {
+			return fmt.Errorf("cannot group by %q", field)
+		}
+	}
+	if q.Bucket < 0 || (q.Bucket > 0 && q.Bucket < time.Millisecond) {
+		return fmt.Errorf("bucket must be at least 1ms")
+	}
+
+	return nil
+}
+
+func (q AggregateQuery) groups(field string) bool {
+	for _, f := range q.GroupBy {
+		if f == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+type aggregateKey struct {
+	bucket   int64
+	name     string
+	severity string
+	service  string
+}
+
+// aggregateEntries is the in-process equivalent of the mongo pipeline,
+// used by the stores that can only iterate over their entries.
+func aggregateEntries(each func(Filter, func(*LogEntry) error) error, q AggregateQuery) ([]AggregateRow, error) {
+	counts := map[aggregateKey]int64{}
+
+	err := each(q.Filter, func(entry *LogEntry) error {
+		var key aggregateKey
+		if q.groups("name") {
+			key.name = entry.Name
+		}
+		if q.groups("severity") {
+			key.severity = entry.Severity
+		}
+		if q.groups("service") {
+			key.service = entry.Service
+		}
+		if q.Bucket > 0 {
+			// aligned on the unix epoch, like the mongo pipeline
+			millis := entry.CreatedAt.UnixMilli()
+			key.bucket = millis - millis%q.Bucket.Milliseconds()
+		}
+
+		counts[key]++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]AggregateRow, 0, len(counts))
+	for key, count := range counts {
+		row := AggregateRow{
+			Name:     key.name,
+			Severity: key.severity,
+			Service:  key.service,
+			Count:    count,
+		}
+		if q.Bucket > 0 {
+			bucket := time.UnixMilli(key.bucket).UTC()
+			row.Bucket = &bucket
+		}
+		rows = append(rows, row)
+	}
+
+	return sortRows(rows, q.Limit), nil
+}
+
+func sortRows(rows []AggregateRow, limit int) []AggregateRow {
+	if limit > 0 {
+		sort.SliceStable(rows, func(i, j int) bool {
+			return rows[i].Count > rows[j].Count
+		})
+		if len(rows) > limit {
+			rows = rows[:limit]
+		}
+		return rows
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.Bucket != nil && b.Bucket != nil && !a.Bucket.Equal(*b.Bucket) {
+			return a.Bucket.Before(*b.Bucket)
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Severity != b.Severity {
+			return a.Severity < b.Severity
+		}
+		return a.Service < b.Service
+	})
+
+	return rows
+}
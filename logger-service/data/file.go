@@ -0,0 +1,617 @@
+package data
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FileStore keeps entries as JSON lines. The search index is built on the
+// first search and kept up to date on inserts and rewrites. The set of
+// ids is loaded on the first insert so duplicates are refused without a
+// scan. Repeat counts are appended to a sidecar file, laid over entries as
+// they are read and folded into the main file by the next rewrite.
+type FileStore struct {
+	mu      sync.RWMutex
+	path    string
+	ids     map[string]struct{}
+	repeats map[string]repeatRecord
+
+	index   *invertedIndex
+	offsets map[string]int64
+}
+
+func NewFileStore(path string) (*FileStore, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	store := &FileStore{path: path}
+
+	store.repeats, err = readRepeats(store.repeatsPath())
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+type repeatRecord struct {
+	ID        string    `json:"id"`
+	Repeat    int64     `json:"repeat"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+func (r repeatRecord) apply(entry *LogEntry) {
+	firstSeen, lastSeen := r.FirstSeen, r.LastSeen
+	entry.Repeat = r.Repeat
+	entry.FirstSeen = &firstSeen
+	entry.LastSeen = &lastSeen
+}
+
+func (f *FileStore) repeatsPath() string {
+	return f.path + ".repeats"
+}
+
+// readRepeats loads the sidecar; the last record for an id wins and a line
+// cut short by a crash is skipped.
+func readRepeats(path string) (map[string]repeatRecord, error) {
+	repeats := map[string]repeatRecord{}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return repeats, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record repeatRecord
+		if json.Unmarshal(scanner.Bytes(), &record) == nil {
+			repeats[record.ID] = record
+		}
+	}
+
+	return repeats, scanner.Err()
+}
+
+// withRepeats lays the repeat counts over entries before fn sees them.
+func withRepeats(repeats map[string]repeatRecord, fn func(entry *LogEntry) error) func(entry *LogEntry) error {
+	if len(repeats) == 0 {
+		return fn
+	}
+
+	return func(entry *LogEntry) error {
+		if record, ok := repeats[entry.ID]; ok {
+			record.apply(entry)
+		}
+		return fn(entry)
+	}
+}
+
+func (f *FileStore) Insert(ctx context.Context, entry LogEntry) error {
+	entry = prepareInsert(entry)
+	if entry.ID == "" {
+		entry.ID = primitive.NewObjectID().Hex()
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.ids == nil {
+		err = f.loadIDs()
+		if err != nil {
+			log.Println("error inserting log: ", err)
+			return err
+		}
+	}
+	if _, ok := f.ids[entry.ID]; ok {
+		return ErrDuplicate
+	}
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Println("error inserting log: ", err)
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		log.Println("error inserting log: ", err)
+		return err
+	}
+
+	_, err = file.Write(append(line, '\n'))
+	if err != nil {
+		log.Println("error inserting log: ", err)
+		return err
+	}
+
+	f.ids[entry.ID] = struct{}{}
+	if f.index != nil {
+		f.index.add(&entry)
+		f.offsets[entry.ID] = info.Size()
+	}
+
+	return nil
+}
+
+func (f *FileStore) loadIDs() error {
+	ids := map[string]struct{}{}
+
+	err := f.scan(func(entry *LogEntry) error {
+		ids[entry.ID] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	f.ids = ids
+
+	return nil
+}
+
+func (f *FileStore) All() ([]*LogEntry, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	entries, err := f.readAll()
+	if err != nil {
+		log.Println("error getting all log entries: ", err)
+		return nil, err
+	}
+
+	sortNewestFirst(entries)
+
+	return entries, nil
+}
+
+func (f *FileStore) FindOne(id string) (*LogEntry, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var found *LogEntry
+	err := f.scan(func(entry *LogEntry) error {
+		if entry.ID == id {
+			found = entry
+			return errStopScan
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("failed getting entry: ", err)
+		return nil, err
+	}
+
+	if found == nil {
+		return nil, ErrNotFound
+	}
+
+	return found, nil
+}
+
+func (f *FileStore) Update(entry LogEntry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries, err := f.readAll()
+	if err != nil {
+		log.Println("failed updating entry: ", err)
+		return err
+	}
+
+	updated := false
+	for _, e := range entries {
+		if e.ID == entry.ID {
+			e.Name = entry.Name
+			e.Data = entry.Data
+			e.UpdatedAt = time.Now()
+			updated = true
+		}
+	}
+
+	if !updated {
+		return ErrNotFound
+	}
+
+	return f.rewrite(entries)
+}
+
+func (f *FileStore) UpdateRepeats(updates []RepeatUpdate) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.ids == nil {
+		err := f.loadIDs()
+		if err != nil {
+			log.Println("failed updating repeats: ", err)
+			return nil, err
+		}
+	}
+
+	var missing []string
+	var lines []byte
+	var records []repeatRecord
+	for _, u := range updates {
+		if _, ok := f.ids[u.ID]; !ok {
+			missing = append(missing, u.ID)
+			continue
+		}
+
+		record := repeatRecord{ID: u.ID, Repeat: u.Repeat, FirstSeen: u.FirstSeen, LastSeen: u.LastSeen}
+		line, err := json.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(append(lines, line...), '\n')
+		records = append(records, record)
+	}
+
+	if len(records) == 0 {
+		return missing, nil
+	}
+
+	file, err := os.OpenFile(f.repeatsPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Println("failed updating repeats: ", err)
+		return nil, err
+	}
+	defer file.Close()
+
+	_, err = file.Write(lines)
+	if err != nil {
+		log.Println("failed updating repeats: ", err)
+		return nil, err
+	}
+
+	for _, record := range records {
+		f.repeats[record.ID] = record
+	}
+
+	return missing, nil
+}
+
+func (f *FileStore) DropCollection() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	err := f.rewrite(nil)
+	if err != nil {
+		log.Println("failed dropping collection: ", err)
+		return err
+	}
+
+	return nil
+}
+
+func (f *FileStore) Count(filter Filter) (int64, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var count int64
+	err := f.scan(func(entry *LogEntry) error {
+		if filter.matches(entry) {
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("failed counting entries: ", err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (f *FileStore) Delete(filter Filter) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries, err := f.readAll()
+	if err != nil {
+		log.Println("failed deleting entries: ", err)
+		return 0, err
+	}
+
+	var kept []*LogEntry
+	var deleted int64
+	for _, entry := range entries {
+		if filter.matches(entry) {
+			deleted++
+			continue
+		}
+		kept = append(kept, entry)
+	}
+
+	if deleted == 0 {
+		return 0, nil
+	}
+
+	err = f.rewrite(kept)
+	if err != nil {
+		log.Println("failed deleting entries: ", err)
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+// Each reads the file as it was when called without holding the lock, so
+// a slow consumer doesn't block inserts. The file is only ever appended to
+// or replaced, so the bytes up to its size at that point don't change.
+func (f *FileStore) Each(filter Filter, fn func(entry *LogEntry) error) error {
+	f.mu.RLock()
+	file, err := os.Open(f.path)
+	var info os.FileInfo
+	if err == nil {
+		info, err = file.Stat()
+		if err != nil {
+			file.Close()
+		}
+	}
+	repeats := make(map[string]repeatRecord, len(f.repeats))
+	for id, record := range f.repeats {
+		repeats[id] = record
+	}
+	f.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return scanEntries(io.NewSectionReader(file, 0, info.Size()), withRepeats(repeats, func(entry *LogEntry) error {
+		if !filter.matches(entry) {
+			return nil
+		}
+		return fn(entry)
+	}))
+}
+
+func (f *FileStore) Aggregate(query AggregateQuery) ([]AggregateRow, error) {
+	return aggregateEntries(f.Each, query)
+}
+
+func (f *FileStore) Search(query SearchQuery) ([]SearchResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.index == nil {
+		err := f.buildIndex()
+		if err != nil {
+			log.Println("failed building search index: ", err)
+			return nil, err
+		}
+	}
+
+	var offsets []int64
+	for id := range f.index.candidates(query) {
+		offsets = append(offsets, f.offsets[id])
+	}
+	sort.Slice(offsets, func(i, j int) bool {
+		return offsets[i] < offsets[j]
+	})
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var results []SearchResult
+	for _, offset := range offsets {
+		line, err := bufio.NewReader(io.NewSectionReader(file, offset, 1<<62)).ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+
+		var entry LogEntry
+		err = json.Unmarshal(line, &entry)
+		if err != nil {
+			return nil, err
+		}
+		if record, ok := f.repeats[entry.ID]; ok {
+			record.apply(&entry)
+		}
+
+		if !query.Filter.matches(&entry) {
+			continue
+		}
+		if result, ok := query.result(&entry); ok {
+			results = append(results, result)
+		}
+	}
+
+	return rankResults(results, query.Limit), nil
+}
+
+func (f *FileStore) buildIndex() error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	index := newInvertedIndex()
+	offsets := map[string]int64{}
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 1 {
+			var entry LogEntry
+			if err := json.Unmarshal(line, &entry); err != nil {
+				return err
+			}
+			index.add(&entry)
+			offsets[entry.ID] = offset
+		}
+		offset += int64(len(line))
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	f.index = index
+	f.offsets = offsets
+
+	return nil
+}
+
+func (f *FileStore) Ping() error {
+	_, err := os.Stat(filepath.Dir(f.path))
+	return err
+}
+
+var errStopScan = errors.New("stop scan")
+
+func (f *FileStore) scan(fn func(entry *LogEntry) error) error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return scanEntries(file, withRepeats(f.repeats, fn))
+}
+
+func scanEntries(r io.Reader, fn func(entry *LogEntry) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry LogEntry
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return err
+		}
+
+		err = fn(&entry)
+		if errors.Is(err, errStopScan) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (f *FileStore) readAll() ([]*LogEntry, error) {
+	var entries []*LogEntry
+
+	err := f.scan(func(entry *LogEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// rewrite replaces the file with entries, which carry the repeat counts
+// read from the sidecar, so the sidecar is emptied; the search index is
+// rebuilt from them when it has been built.
+func (f *FileStore) rewrite(entries []*LogEntry) error {
+	indexed := f.index != nil
+	f.index = nil
+	tmpPath := f.path + ".tmp"
+
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	offsets := make(map[string]int64, len(entries))
+	var offset int64
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err == nil {
+			_, err = writer.Write(append(line, '\n'))
+		}
+		if err != nil {
+			file.Close()
+			return err
+		}
+
+		offsets[entry.ID] = offset
+		offset += int64(len(line)) + 1
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmpPath, f.path)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(f.repeatsPath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	f.repeats = map[string]repeatRecord{}
+
+	f.ids = make(map[string]struct{}, len(entries))
+	for _, entry := range entries {
+		f.ids[entry.ID] = struct{}{}
+	}
+
+	if indexed {
+		f.index = newInvertedIndex()
+		for _, entry := range entries {
+			f.index.add(entry)
+		}
+		f.offsets = offsets
+	}
+
+	return nil
+}
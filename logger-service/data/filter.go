@@ -0,0 +1,102 @@
+package data
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type Filter struct {
+	Name     string
+	Severity string
+	Service  string
+	Since    time.Time
+	Until    time.Time
+
+	// UpdatedSince and UpdatedUntil bound updated_at, the time the entry
+	// was last written to the store.
+	UpdatedSince time.Time
+	UpdatedUntil time.Time
+}
+
+// Find collects every entry matching the filter, newest first.
+func Find(store LogStore, filter Filter) ([]*LogEntry, error) {
+	var entries []*LogEntry
+
+	err := store.Each(filter, func(entry *LogEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortNewestFirst(entries)
+
+	return entries, nil
+}
+
+func (f Filter) matches(entry *LogEntry) bool {
+	if f.Name != "" && entry.Name != f.Name {
+		return false
+	}
+	if f.Severity != "" && entry.Severity != strings.ToUpper(f.Severity) {
+		return false
+	}
+	if f.Service != "" && entry.Service != f.Service {
+		return false
+	}
+	if !f.Since.IsZero() && entry.CreatedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !entry.CreatedAt.Before(f.Until) {
+		return false
+	}
+	if !f.UpdatedSince.IsZero() && entry.UpdatedAt.Before(f.UpdatedSince) {
+		return false
+	}
+	if !f.UpdatedUntil.IsZero() && !entry.UpdatedAt.Before(f.UpdatedUntil) {
+		return false
+	}
+
+	return true
+}
+
+func (f Filter) bson() bson.M {
+	query := bson.M{}
+
+	if f.Name != "" {
+		query["name"] = f.Name
+	}
+	if f.Severity != "" {
+		query["severity"] = strings.ToUpper(f.Severity)
+	}
+	if f.Service != "" {
+		query["service"] = f.Service
+	}
+
+	createdAt := bson.M{}
+	if !f.Since.IsZero() {
+		createdAt["$gte"] = f.Since
+	}
+	if !f.Until.IsZero() {
+		createdAt["$lt"] = f.Until
+	}
+	if len(createdAt) > 0 {
+		query["created_at"] = createdAt
+	}
+
+	updatedAt := bson.M{}
+	if !f.UpdatedSince.IsZero() {
+		updatedAt["$gte"] = f.UpdatedSince
+	}
+	if !f.UpdatedUntil.IsZero() {
+		updatedAt["$lt"] = f.UpdatedUntil
+	}
+	if len(updatedAt) > 0 {
+		query["updated_at"] = updatedAt
+	}
+
+	return query
+}
@@ -0,0 +1,80 @@
+package data
+
+// invertedIndex maps each token of an entry's data to the ids of the
+// entries containing it.
+type invertedIndex struct {
+	postings map[string]map[string]struct{}
+	tokens   map[string][]string
+}
+
+func newInvertedIndex() *invertedIndex {
+	return &invertedIndex{
+		postings: map[string]map[string]struct{}{},
+		tokens:   map[string][]string{},
+	}
+}
+
+func (x *invertedIndex) add(entry *LogEntry) {
+	x.remove(entry.ID)
+
+	seen := map[string]bool{}
+	var words []string
+	for _, t := range tokenize(entry.Data) {
+		if seen[t.text] {
+			continue
+		}
+		seen[t.text] = true
+		words = append(words, t.text)
+
+		ids, ok := x.postings[t.text]
+		if !ok {
+			ids = map[string]struct{}{}
+			x.postings[t.text] = ids
+		}
+		ids[entry.ID] = struct{}{}
+	}
+	x.tokens[entry.ID] = words
+}
+
+func (x *invertedIndex) remove(id string) {
+	for _, word := range x.tokens[id] {
+		delete(x.postings[word], id)
+		if len(x.postings[word]) == 0 {
+			delete(x.postings, word)
+		}
+	}
+	delete(x.tokens, id)
+}
+
+// candidates returns the ids of entries that contain every word of at least
+// one alternative; the query itself still decides phrases and exclusions.
+func (x *invertedIndex) candidates(q SearchQuery) map[string]struct{} {
+	found := map[string]struct{}{}
+
+	for _, group := range q.groups {
+		var ids map[string]struct{}
+		for _, c := range positiveClauses(group) {
+			for _, word := range c.words {
+				postings := x.postings[word]
+				if ids == nil {
+					ids = make(map[string]struct{}, len(postings))
+					for id := range postings {
+						ids[id] = struct{}{}
+					}
+					continue
+				}
+				for id := range ids {
+					if _, ok := postings[id]; !ok {
+						delete(ids, id)
+					}
+				}
+			}
+		}
+
+		for id := range ids {
+			found[id] = struct{}{}
+		}
+	}
+
+	return found
+}
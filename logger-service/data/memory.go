@@ -0,0 +1,209 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries []LogEntry
+	ids     map[string]struct{}
+	index   *invertedIndex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{ids: map[string]struct{}{}, index: newInvertedIndex()}
+}
+
+func (m *MemoryStore) Insert(ctx context.Context, entry LogEntry) error {
+	entry = prepareInsert(entry)
+	if entry.ID == "" {
+		entry.ID = primitive.NewObjectID().Hex()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.ids[entry.ID]; ok {
+		return ErrDuplicate
+	}
+
+	m.entries = append(m.entries, entry)
+	m.ids[entry.ID] = struct{}{}
+	m.index.add(&entry)
+
+	return nil
+}
+
+func (m *MemoryStore) All() ([]*LogEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := make([]*LogEntry, 0, len(m.entries))
+	for i := range m.entries {
+		entry := m.entries[i]
+		entries = append(entries, &entry)
+	}
+
+	sortNewestFirst(entries)
+
+	return entries, nil
+}
+
+func (m *MemoryStore) FindOne(id string) (*LogEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, entry := range m.entries {
+		if entry.ID == id {
+			return &entry, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (m *MemoryStore) Update(entry LogEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.entries {
+		if m.entries[i].ID == entry.ID {
+			m.entries[i].Name = entry.Name
+			m.entries[i].Data = entry.Data
+			m.entries[i].UpdatedAt = time.Now()
+			m.index.add(&m.entries[i])
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
+
+func (m *MemoryStore) UpdateRepeats(updates []RepeatUpdate) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var missing []string
+	byID := make(map[string]RepeatUpdate, len(updates))
+	for _, u := range updates {
+		if _, ok := m.ids[u.ID]; !ok {
+			missing = append(missing, u.ID)
+			continue
+		}
+		byID[u.ID] = u
+	}
+
+	for i := range m.entries {
+		if u, ok := byID[m.entries[i].ID]; ok {
+			applyRepeat(&m.entries[i], u)
+		}
+	}
+
+	return missing, nil
+}
+
+func (m *MemoryStore) DropCollection() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries = nil
+	m.ids = map[string]struct{}{}
+	m.index = newInvertedIndex()
+
+	return nil
+}
+
+func (m *MemoryStore) Count(filter Filter) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var count int64
+	for i := range m.entries {
+		if filter.matches(&m.entries[i]) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (m *MemoryStore) Delete(filter Filter) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := m.entries[:0]
+	var deleted int64
+	for _, entry := range m.entries {
+		if filter.matches(&entry) {
+			m.index.remove(entry.ID)
+			delete(m.ids, entry.ID)
+			deleted++
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	m.entries = kept
+
+	return deleted, nil
+}
+
+func (m *MemoryStore) Each(filter Filter, fn func(entry *LogEntry) error) error {
+	m.mu.RLock()
+	var matched []LogEntry
+	for i := range m.entries {
+		if filter.matches(&m.entries[i]) {
+			matched = append(matched, m.entries[i])
+		}
+	}
+	m.mu.RUnlock()
+
+	for i := range matched {
+		err := fn(&matched[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sortNewestFirst(entries []*LogEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.After(entries[j].CreatedAt)
+	})
+}
+
+func (m *MemoryStore) Aggregate(query AggregateQuery) ([]AggregateRow, error) {
+	return aggregateEntries(m.Each, query)
+}
+
+func (m *MemoryStore) Ping() error {
+	return nil
+}
+
+func (m *MemoryStore) Search(query SearchQuery) ([]SearchResult, error) {
+	m.mu.RLock()
+	candidates := m.index.candidates(query)
+	var matched []LogEntry
+	for i := range m.entries {
+		if _, ok := candidates[m.entries[i].ID]; ok && query.Filter.matches(&m.entries[i]) {
+			matched = append(matched, m.entries[i])
+		}
+	}
+	m.mu.RUnlock()
+
+	var results []SearchResult
+	for i := range matched {
+		if result, ok := query.result(&matched[i]); ok {
+			results = append(results, result)
+		}
+	}
+
+	return rankResults(results, query.Limit), nil
+}
@@ -2,151 +2,86 @@ package data
 
 import (
 	"context"
-	"log"
+	"errors"
+	"strings"
 	"time"
-
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var client *mongo.Client
+var (
+	ErrNotFound  = errors.New("log entry not found")
+	ErrDuplicate = errors.New("log entry already exists")
+)
 
 type LogEntry struct {
-	ID        string    `bson:"_id,omitempty" json:"id,omitempty"`
-	Name      string    `bson:"name" json:"name"`
-	Data      string    `bson:"data" json:"data"`
-	CreatedAt time.Time `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+	ID         string            `bson:"_id,omitempty" json:"id,omitempty"`
+	Name       string            `bson:"name" json:"name"`
+	Data       string            `bson:"data" json:"data"`
+	Severity   string            `bson:"severity,omitempty" json:"severity,omitempty"`
+	Service    string            `bson:"service,omitempty" json:"service,omitempty"`
+	Attributes map[string]string `bson:"attributes,omitempty" json:"attributes,omitempty"`
+	TraceID    string            `bson:"trace_id,omitempty" json:"trace_id,omitempty"`
+	SpanID     string            `bson:"span_id,omitempty" json:"span_id,omitempty"`
+	ChainSeq   int64             `bson:"chain_seq,omitempty" json:"chain_seq,omitempty"`
+	PrevHash   string            `bson:"prev_hash,omitempty" json:"prev_hash,omitempty"`
+	ChainHash  string            `bson:"chain_hash,omitempty" json:"chain_hash,omitempty"`
+	Repeat     int64             `bson:"repeat,omitempty" json:"repeat,omitempty"`
+	FirstSeen  *time.Time        `bson:"first_seen,omitempty" json:"first_seen,omitempty"`
+	LastSeen   *time.Time        `bson:"last_seen,omitempty" json:"last_seen,omitempty"`
+	CreatedAt  time.Time         `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time         `bson:"updated_at" json:"updated_at"`
 }
 
-type Models struct {
-	LogEntry LogEntry
+type LogStore interface {
+	Insert(ctx context.Context, entry LogEntry) error
+	All() ([]*LogEntry, error)
+	FindOne(id string) (*LogEntry, error)
+	Update(entry LogEntry) error
+	UpdateRepeats(updates []RepeatUpdate) (missing []string, err error)
+	DropCollection() error
+	Count(filter Filter) (int64, error)
+	Delete(filter Filter) (int64, error)
+	Each(filter Filter, fn func(entry *LogEntry) error) error
+	Aggregate(query AggregateQuery) ([]AggregateRow, error)
+	Search(query SearchQuery) ([]SearchResult, error)
+	Ping() error
 }
 
-func New(mongo *mongo.Client) Models {
-	client = mongo
+type Models struct {
+	LogEntry LogStore
+}
 
+func New(store LogStore) Models {
 	return Models{
-		LogEntry: LogEntry{},
+		LogEntry: store,
 	}
 }
 
-func (l *LogEntry) Insert(entry LogEntry) error {
-	collection := client.Database("logs").Collection("logs")
+func prepareInsert(entry LogEntry) LogEntry {
+	now := time.Now()
 
-	_, err := collection.InsertOne(context.TODO(), LogEntry{
-		ID:        entry.ID,
-		Name:      entry.Name,
-		Data:      entry.Data,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	})
-	if err != nil {
-		log.Println("error inserting log: ", err)
-		return err
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = now
 	}
+	entry.UpdatedAt = now
+	entry.Severity = strings.ToUpper(entry.Severity)
 
-	return nil
+	return entry
 }
 
-func (l *LogEntry) All() ([]*LogEntry, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
-	defer cancel()
-
-	collection := client.Database("logs").Collection("logs")
-
-	opts := options.Find()
-	opts.SetSort(bson.D{{Key: "created_at", Value: -1}})
-
-	cursor, err := collection.Find(context.TODO(), bson.D{}, opts)
-	if err != nil {
-		log.Println("error getting all log entries: ", err)
-		return nil, err
-	}
-	defer cursor.Close(ctx)
-
-	var entries []*LogEntry
-
-	for cursor.Next(ctx) {
-		var entry LogEntry
-
-		err := cursor.Decode(&entry)
-		if err != nil {
-			log.Println("error getting all log entries: ", err)
-			return nil, err
-		}
-
-		entries = append(entries, &entry)
-	}
-
-	return entries, nil
+// RepeatUpdate records how often an entry was seen again within the dedup
+// window. UpdateRepeats returns the ids it found no entry for, such as
+// entries still waiting in the spool. Repeat counts leave updated_at alone,
+// so they don't get an archived entry archived again.
+type RepeatUpdate struct {
+	ID        string
+	Repeat    int64
+	FirstSeen time.Time
+	LastSeen  time.Time
 }
 
-func (l *LogEntry) FindOne(id string) (*LogEntry, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
-	defer cancel()
-
-	collection := client.Database("logs").Collection("logs")
-
-	docId, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		log.Println("failed getting entry: ", err)
-		return nil, err
-	}
-
-	var entry LogEntry
-	err = collection.FindOne(ctx, bson.M{"_id": docId}).Decode(&entry)
-	if err != nil {
-		log.Println("failed getting entry: ", err)
-		return nil, err
-	}
-
-	return &entry, nil
-}
-
-func (l *LogEntry) DropCollection() error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
-	defer cancel()
-
-	collection := client.Database("logs").Collection("logs")
-	
-	err := collection.Drop(ctx)
-	if err != nil {
-		log.Println("failed dropping collection: ", err)
-		return err
-	}
-
-	return nil
+func applyRepeat(entry *LogEntry, u RepeatUpdate) {
+	firstSeen, lastSeen := u.FirstSeen, u.LastSeen
+	entry.Repeat = u.Repeat
+	entry.FirstSeen = &firstSeen
+	entry.LastSeen = &lastSeen
 }
-
-func (l *LogEntry) Update() (*mongo.UpdateResult, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
-	defer cancel()
-
-	collection := client.Database("logs").Collection("logs")
-
-	docId, err := primitive.ObjectIDFromHex(l.ID)
-	if err != nil {
-		log.Println("failed updating entry: ", err)
-		return nil, err
-	}
-
-	result, err := collection.UpdateOne(ctx,
-		bson.M{"_id": docId},
-		bson.D{
-			{Key: "set", Value: bson.D{
-				{Key: "name", Value: l.Name},
-				{Key: "data", Value: l.Data},
-				{Key: "updated_at", Value: time.Now()},
-			}},
-		},
-	)
-	if err != nil {
-		log.Println("failed updating entry: ", err)
-		return nil, err
-	}
-
-	return result, nil
-}
\ No newline at end of file
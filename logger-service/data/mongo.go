@@ -0,0 +1,436 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoStore struct {
+	client *mongo.Client
+
+	textIndexMu    sync.Mutex
+	textIndexReady bool
+}
+
+func NewMongoStore(client *mongo.Client) *MongoStore {
+	return &MongoStore{client: client}
+}
+
+func (m *MongoStore) collection() *mongo.Collection {
+	return m.client.Database("logs").Collection("logs")
+}
+
+func (m *MongoStore) Insert(ctx context.Context, entry LogEntry) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*15)
+	defer cancel()
+
+	doc, err := mongoDocument(prepareInsert(entry))
+	if err != nil {
+		log.Println("error inserting log: ", err)
+		return err
+	}
+
+	_, err = m.collection().InsertOne(ctx, doc)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	if err != nil {
+		log.Println("error inserting log: ", err)
+		return err
+	}
+
+	return nil
+}
+
+func (m *MongoStore) All() ([]*LogEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	opts := options.Find()
+	opts.SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := m.collection().Find(ctx, bson.D{}, opts)
+	if err != nil {
+		log.Println("error getting all log entries: ", err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []*LogEntry
+
+	for cursor.Next(ctx) {
+		var entry LogEntry
+
+		err := cursor.Decode(&entry)
+		if err != nil {
+			log.Println("error getting all log entries: ", err)
+			return nil, err
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	return entries, nil
+}
+
+func (m *MongoStore) FindOne(id string) (*LogEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	docId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		log.Println("failed getting entry: ", err)
+		return nil, err
+	}
+
+	var entry LogEntry
+	err = m.collection().FindOne(ctx, bson.M{"_id": docId}).Decode(&entry)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		log.Println("failed getting entry: ", err)
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+func (m *MongoStore) DropCollection() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	err := m.collection().Drop(ctx)
+	if err != nil {
+		log.Println("failed dropping collection: ", err)
+		return err
+	}
+
+	return nil
+}
+
+func (m *MongoStore) Update(entry LogEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	docId, err := primitive.ObjectIDFromHex(entry.ID)
+	if err != nil {
+		log.Println("failed updating entry: ", err)
+		return err
+	}
+
+	result, err := m.collection().UpdateOne(ctx,
+		bson.M{"_id": docId},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "name", Value: entry.Name},
+				{Key: "data", Value: entry.Data},
+				{Key: "updated_at", Value: time.Now()},
+			}},
+		},
+	)
+	if err != nil {
+		log.Println("failed updating entry: ", err)
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (m *MongoStore) UpdateRepeats(updates []RepeatUpdate) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	var models []mongo.WriteModel
+	var docIds []primitive.ObjectID
+	for _, u := range updates {
+		docId, err := primitive.ObjectIDFromHex(u.ID)
+		if err != nil {
+			continue
+		}
+		docIds = append(docIds, docId)
+
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": docId}).
+			SetUpdate(bson.D{
+				{Key: "$set", Value: bson.D{
+					{Key: "repeat", Value: u.Repeat},
+					{Key: "first_seen", Value: u.FirstSeen},
+					{Key: "last_seen", Value: u.LastSeen},
+				}},
+			}))
+	}
+
+	if len(models) == 0 {
+		return nil, nil
+	}
+
+	result, err := m.collection().BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		log.Println("failed updating repeats: ", err)
+		return nil, err
+	}
+	if result.MatchedCount == int64(len(models)) {
+		return nil, nil
+	}
+
+	// the bulk result only has totals, so look up which ids matched
+	cursor, err := m.collection().Find(ctx,
+		bson.M{"_id": bson.M{"$in": docIds}},
+		options.Find().SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		log.Println("failed updating repeats: ", err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	found := map[primitive.ObjectID]bool{}
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if cursor.Decode(&doc) == nil {
+			found[doc.ID] = true
+		}
+	}
+
+	var missing []string
+	for _, docId := range docIds {
+		if !found[docId] {
+			missing = append(missing, docId.Hex())
+		}
+	}
+
+	return missing, cursor.Err()
+}
+
+func (m *MongoStore) Count(filter Filter) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+	defer cancel()
+
+	count, err := m.collection().CountDocuments(ctx, filter.bson())
+	if err != nil {
+		log.Println("failed counting entries: ", err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (m *MongoStore) Delete(filter Filter) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
+	defer cancel()
+
+	result, err := m.collection().DeleteMany(ctx, filter.bson())
+	if err != nil {
+		log.Println("failed deleting entries: ", err)
+		return 0, err
+	}
+
+	return result.DeletedCount, nil
+}
+
+func (m *MongoStore) Each(filter Filter, fn func(entry *LogEntry) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	opts := options.Find()
+	opts.SetSort(bson.D{{Key: "created_at", Value: 1}})
+
+	cursor, err := m.collection().Find(ctx, filter.bson(), opts)
+	if err != nil {
+		log.Println("error iterating log entries: ", err)
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var entry LogEntry
+
+		err := cursor.Decode(&entry)
+		if err != nil {
+			log.Println("error iterating log entries: ", err)
+			return err
+		}
+
+		err = fn(&entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// mongoDocument stores hex ids (e.g. from an imported archive) as ObjectIDs
+// so that they stay reachable through FindOne.
+func mongoDocument(entry LogEntry) (bson.M, error) {
+	raw, err := bson.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc bson.M
+	err = bson.Unmarshal(raw, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if id, err := primitive.ObjectIDFromHex(entry.ID); err == nil {
+		doc["_id"] = id
+	}
+
+	return doc, nil
+}
+
+func (m *MongoStore) Aggregate(query AggregateQuery) ([]AggregateRow, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
+	defer cancel()
+
+	group := bson.M{}
+	for _, field := range query.GroupBy {
+		group[field] = "$" + field
+	}
+	if query.Bucket > 0 {
+		// $dateTrunc needs mongo 5, so buckets are computed from epoch millis
+		millis := bson.M{"$toLong": "$created_at"}
+		group["bucket"] = bson.M{"$toDate": bson.M{"$subtract": bson.A{
+			millis,
+			bson.M{"$mod": bson.A{millis, query.Bucket.Milliseconds()}},
+		}}}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: query.Filter.bson()}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   group,
+			"count": bson.M{"$sum": 1},
+		}}},
+	}
+	if query.Limit > 0 {
+		pipeline = append(pipeline,
+			bson.D{{Key: "$sort", Value: bson.M{"count": -1}}},
+			bson.D{{Key: "$limit", Value: query.Limit}},
+		)
+	}
+
+	cursor, err := m.collection().Aggregate(ctx, pipeline)
+	if err != nil {
+		log.Println("failed aggregating entries: ", err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []AggregateRow
+	for cursor.Next(ctx) {
+		var result struct {
+			ID    AggregateRow `bson:"_id"`
+			Count int64        `bson:"count"`
+		}
+
+		err := cursor.Decode(&result)
+		if err != nil {
+			log.Println("failed aggregating entries: ", err)
+			return nil, err
+		}
+
+		row := result.ID
+		row.Count = result.Count
+		if row.Bucket != nil {
+			bucket := row.Bucket.UTC()
+			row.Bucket = &bucket
+		}
+		rows = append(rows, row)
+	}
+
+	return sortRows(rows, query.Limit), cursor.Err()
+}
+
+func (m *MongoStore) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+
+	return m.client.Ping(ctx, nil)
+}
+
+// ensureTextIndex creates the text index on data without stemming or stop
+// words, so that it agrees with the query tokens. A failed attempt is
+// retried by the next search.
+func (m *MongoStore) ensureTextIndex() error {
+	m.textIndexMu.Lock()
+	defer m.textIndexMu.Unlock()
+
+	if m.textIndexReady {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*60)
+	defer cancel()
+
+	_, err := m.collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "data", Value: "text"}},
+		Options: options.Index().SetName("data_text").SetDefaultLanguage("none"),
+	})
+	if err != nil {
+		log.Println("error creating text index: ", err)
+		return err
+	}
+	m.textIndexReady = true
+
+	return nil
+}
+
+// Search narrows candidates with the text index and lets the query decide
+// phrases, exclusions and scoring. Every candidate is scored before the
+// limit applies, so results match the other stores, but only the best ones
+// are held on the way.
+func (m *MongoStore) Search(query SearchQuery) ([]SearchResult, error) {
+	err := m.ensureTextIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	filter := query.Filter.bson()
+	filter["$text"] = bson.M{"$search": strings.Join(query.terms(), " "), "$language": "none"}
+
+	cursor, err := m.collection().Find(ctx, filter)
+	if err != nil {
+		log.Println("error searching log entries: ", err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	top := newTopResults(query.Limit)
+	for cursor.Next(ctx) {
+		var entry LogEntry
+
+		err := cursor.Decode(&entry)
+		if err != nil {
+			log.Println("error searching log entries: ", err)
+			return nil, err
+		}
+
+		if result, ok := query.result(&entry); ok {
+			top.add(result)
+		}
+	}
+
+	return top.ranked(), cursor.Err()
+}
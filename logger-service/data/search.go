@@ -0,0 +1,417 @@
+package data
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defaultSearchLimit = 50
+	snippetSize        = 160
+)
+
+var ErrInvalidSearch = errors.New("invalid search query")
+
+// SearchQuery is a parsed full-text query over entry data. Terms are ANDed,
+// OR (or "|") separates alternatives, "quoted text" matches a phrase and NOT
+// or a leading "-" excludes a term or phrase.
+type SearchQuery struct {
+	Text   string
+	Filter Filter
+	Limit  int
+	groups [][]clause
+}
+
+type clause struct {
+	words  []string
+	negate bool
+}
+
+type SearchResult struct {
+	Entry   *LogEntry `json:"entry"`
+	Score   float64   `json:"score"`
+	Snippet string    `json:"snippet"`
+}
+
+type token struct {
+	text       string
+	start, end int
+}
+
+func ParseSearch(text string) (SearchQuery, error) {
+	q := SearchQuery{Text: text, Limit: defaultSearchLimit}
+
+	var group []clause
+	negate := false
+	for _, term := range splitSearch(text) {
+		switch {
+		case !term.quoted && (term.text == "OR" || term.text == "|"):
+			if len(group) > 0 {
+				q.groups = append(q.groups, group)
+			}
+			group = nil
+			negate = false
+			continue
+		case !term.quoted && term.text == "AND":
+			continue
+		case !term.quoted && term.text == "NOT":
+			negate = true
+			continue
+		}
+
+		value := term.text
+		if !term.quoted && strings.HasPrefix(value, "-") {
+			negate = true
+			value = value[1:]
+		}
+
+		words := tokenWords(tokenize(value))
+		if len(words) > 0 {
+			group = append(group, clause{words: words, negate: negate})
+		}
+		negate = false
+	}
+	if len(group) > 0 {
+		q.groups = append(q.groups, group)
+	}
+
+	if len(q.groups) == 0 {
+		return q, fmt.Errorf("%w: query has no terms", ErrInvalidSearch)
+	}
+	for _, group := range q.groups {
+		if len(positiveClauses(group)) == 0 {
+			return q, fmt.Errorf("%w: every alternative needs a term to match", ErrInvalidSearch)
+		}
+	}
+
+	return q, nil
+}
+
+type searchTerm struct {
+	text   string
+	quoted bool
+}
+
+func splitSearch(text string) []searchTerm {
+	var terms []searchTerm
+
+	for {
+		text = strings.TrimLeftFunc(text, unicode.IsSpace)
+		if text == "" {
+			return terms
+		}
+
+		prefix := ""
+		if strings.HasPrefix(text, `-"`) {
+			prefix, text = "-", text[1:]
+		}
+
+		if strings.HasPrefix(text, `"`) {
+			phrase, rest, _ := strings.Cut(text[1:], `"`)
+			if prefix != "" {
+				terms = append(terms, searchTerm{text: "NOT"})
+			}
+			terms = append(terms, searchTerm{text: phrase, quoted: true})
+			text = rest
+			continue
+		}
+
+		end := strings.IndexFunc(text, unicode.IsSpace)
+		if end < 0 {
+			end = len(text)
+		}
+		terms = append(terms, searchTerm{text: text[:end]})
+		text = text[end:]
+	}
+}
+
+func tokenize(s string) []token {
+	var tokens []token
+
+	start := -1
+	for i, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, token{text: strings.ToLower(s[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{text: strings.ToLower(s[start:]), start: start, end: len(s)})
+	}
+
+	return tokens
+}
+
+func tokenWords(tokens []token) []string {
+	words := make([]string, len(tokens))
+	for i, t := range tokens {
+		words[i] = t.text
+	}
+
+	return words
+}
+
+func positiveClauses(group []clause) []clause {
+	var positive []clause
+	for _, c := range group {
+		if !c.negate {
+			positive = append(positive, c)
+		}
+	}
+
+	return positive
+}
+
+// terms lists every word the query needs to find, used to pick candidates
+// from an index.
+func (q SearchQuery) terms() []string {
+	seen := map[string]bool{}
+	var terms []string
+	for _, group := range q.groups {
+		for _, c := range positiveClauses(group) {
+			for _, word := range c.words {
+				if !seen[word] {
+					seen[word] = true
+					terms = append(terms, word)
+				}
+			}
+		}
+	}
+
+	return terms
+}
+
+// occurrences returns the token index of every place the clause's words
+// appear in order.
+func (c clause) occurrences(tokens []token) []int {
+	var found []int
+	for i := 0; i+len(c.words) <= len(tokens); i++ {
+		matched := true
+		for j, word := range c.words {
+			if tokens[i+j].text != word {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			found = append(found, i)
+		}
+	}
+
+	return found
+}
+
+// evaluate scores an entry by how often the terms of its matching
+// alternatives occur, and returns the byte spans to highlight.
+func (q SearchQuery) evaluate(entry *LogEntry) (float64, [][2]int, bool) {
+	tokens := tokenize(entry.Data)
+
+	var score float64
+	var spans [][2]int
+	matched := false
+
+	for _, group := range q.groups {
+		groupScore := 0
+		var groupSpans [][2]int
+		ok := true
+
+		for _, c := range group {
+			found := c.occurrences(tokens)
+			if c.negate {
+				if len(found) > 0 {
+					ok = false
+					break
+				}
+				continue
+			}
+			if len(found) == 0 {
+				ok = false
+				break
+			}
+
+			groupScore += len(found) * len(c.words)
+			for _, i := range found {
+				groupSpans = append(groupSpans, [2]int{tokens[i].start, tokens[i+len(c.words)-1].end})
+			}
+		}
+
+		if ok {
+			matched = true
+			score += float64(groupScore)
+			spans = append(spans, groupSpans...)
+		}
+	}
+
+	return score, spans, matched
+}
+
+func (q SearchQuery) result(entry *LogEntry) (SearchResult, bool) {
+	score, spans, ok := q.evaluate(entry)
+	if !ok {
+		return SearchResult{}, false
+	}
+
+	return SearchResult{Entry: entry, Score: score, Snippet: snippet(entry.Data, spans)}, true
+}
+
+// snippet cuts a window of the text around the first match and wraps the
+// matches in <mark> tags; the rest of the text is HTML-escaped.
+func snippet(text string, spans [][2]int) string {
+	if len(spans) == 0 {
+		return html.EscapeString(truncate(text, snippetSize))
+	}
+
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i][0] < spans[j][0]
+	})
+
+	start := spans[0][0] - snippetSize/4
+	if start < 0 {
+		start = 0
+	}
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	end := start + snippetSize
+	if end > len(text) {
+		end = len(text)
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+
+	pos := start
+	for _, span := range spans {
+		if span[0] < pos || span[1] > end {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:span[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[span[0]:span[1]]))
+		b.WriteString("</mark>")
+		pos = span[1]
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+
+	if end < len(text) {
+		b.WriteString("…")
+	}
+
+	return b.String()
+}
+
+func truncate(text string, size int) string {
+	if size >= len(text) {
+		return text
+	}
+	for size > 0 && !utf8.RuneStart(text[size]) {
+		size--
+	}
+
+	return text[:size] + "…"
+}
+
+// rankResults orders results by score, newest first on ties, and applies
+// the limit.
+func rankResults(results []SearchResult, limit int) []SearchResult {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Entry.CreatedAt.After(results[j].Entry.CreatedAt)
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results
+}
+
+// topResults keeps the best limit results seen so far in a heap whose root
+// is the worst of them, ranked like rankResults including its stable ties.
+type topResults struct {
+	limit   int
+	results []rankedResult
+	seq     int
+}
+
+type rankedResult struct {
+	SearchResult
+	seq int
+}
+
+func newTopResults(limit int) *topResults {
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	return &topResults{limit: limit}
+}
+
+// below reports whether a ranks after b.
+func below(a, b rankedResult) bool {
+	if a.Score != b.Score {
+		return a.Score < b.Score
+	}
+	if !a.Entry.CreatedAt.Equal(b.Entry.CreatedAt) {
+		return a.Entry.CreatedAt.Before(b.Entry.CreatedAt)
+	}
+	return a.seq > b.seq
+}
+
+func (t *topResults) Len() int           { return len(t.results) }
+func (t *topResults) Less(i, j int) bool { return below(t.results[i], t.results[j]) }
+func (t *topResults) Swap(i, j int)      { t.results[i], t.results[j] = t.results[j], t.results[i] }
+func (t *topResults) Push(x any)         { t.results = append(t.results, x.(rankedResult)) }
+
+func (t *topResults) Pop() any {
+	last := t.results[len(t.results)-1]
+	t.results = t.results[:len(t.results)-1]
+	return last
+}
+
+func (t *topResults) add(result SearchResult) {
+	r := rankedResult{SearchResult: result, seq: t.seq}
+	t.seq++
+
+	if len(t.results) < t.limit {
+		heap.Push(t, r)
+		return
+	}
+	if below(t.results[0], r) {
+		t.results[0] = r
+		heap.Fix(t, 0)
+	}
+}
+
+// ranked returns the kept results best first.
+func (t *topResults) ranked() []SearchResult {
+	sort.Slice(t.results, func(i, j int) bool {
+		return below(t.results[j], t.results[i])
+	})
+
+	results := make([]SearchResult, len(t.results))
+	for i, r := range t.results {
+		results[i] = r.SearchResult
+	}
+
+	return results
+}
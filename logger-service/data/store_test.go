@@ -0,0 +1,262 @@
+package data
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var base = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+var fixtures = []LogEntry{
+	{Name: "auth", Severity: "ERROR", Service: "api", Data: "login failed for user bob", CreatedAt: base.Add(1 * time.Hour)},
+	{Name: "auth", Severity: "INFO", Service: "api", Data: "login ok for user alice", CreatedAt: base.Add(2 * time.Hour)},
+	{Name: "billing", Severity: "ERROR", Service: "worker", Data: "payment failed: card declined", CreatedAt: base.Add(25 * time.Hour)},
+	{Name: "billing", Severity: "info", Service: "worker", Data: "payment ok", CreatedAt: base.Add(26 * time.Hour)},
+	{Name: "auth", Severity: "WARNING", Service: "web", Data: "failed login, failed twice", CreatedAt: base.Add(27 * time.Hour)},
+}
+
+// testStores returns every in-process store seeded with the fixtures; the
+// file store is also checked after being opened again.
+func testStores(t *testing.T) map[string]LogStore {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "logs.jsonl")
+	file, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]LogStore{
+		"memory": NewMemoryStore(),
+		"file":   file,
+	}
+	for name, store := range stores {
+		for _, entry := range fixtures {
+			err := store.Insert(context.Background(), entry)
+			if err != nil {
+				t.Fatalf("%s: insert: %v", name, err)
+			}
+		}
+	}
+
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores["file reopened"] = reopened
+
+	return stores
+}
+
+func data(entries []*LogEntry) []string {
+	out := make([]string, len(entries))
+	for i, entry := range entries {
+		out[i] = entry.Data
+	}
+
+	return out
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"everything", Filter{}, []string{"failed login, failed twice", "payment ok", "payment failed: card declined", "login ok for user alice", "login failed for user bob"}},
+		{"name", Filter{Name: "auth"}, []string{"failed login, failed twice", "login ok for user alice", "login failed for user bob"}},
+		{"severity in any case", Filter{Severity: "info"}, []string{"payment ok", "login ok for user alice"}},
+		{"service", Filter{Service: "worker"}, []string{"payment ok", "payment failed: card declined"}},
+		{"since is inclusive", Filter{Since: base.Add(26 * time.Hour)}, []string{"failed login, failed twice", "payment ok"}},
+		{"until is exclusive", Filter{Until: base.Add(2 * time.Hour)}, []string{"login failed for user bob"}},
+		{"combined", Filter{Name: "auth", Since: base.Add(24 * time.Hour)}, []string{"failed login, failed twice"}},
+		{"no match", Filter{Name: "missing"}, []string{}},
+	}
+
+	for storeName, store := range testStores(t) {
+		for _, tt := range tests {
+			t.Run(storeName+"/"+tt.name, func(t *testing.T) {
+				entries, err := Find(store, tt.filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := data(entries); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("got %q, want %q", got, tt.want)
+				}
+
+				count, err := store.Count(tt.filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if count != int64(len(tt.want)) {
+					t.Errorf("count = %d, want %d", count, len(tt.want))
+				}
+			})
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		query  string
+		filter Filter
+		limit  int
+		want   []string
+	}{
+		// more occurrences rank first, then newer entries
+		{query: "failed", want: []string{"failed login, failed twice", "payment failed: card declined", "login failed for user bob"}},
+		{query: "FAILED", limit: 1, want: []string{"failed login, failed twice"}},
+		{query: "login -bob", want: []string{"failed login, failed twice", "login ok for user alice"}},
+		{query: "login NOT bob", want: []string{"failed login, failed twice", "login ok for user alice"}},
+		{query: `"payment failed"`, want: []string{"payment failed: card declined"}},
+		{query: `failed -"user bob"`, want: []string{"failed login, failed twice", "payment failed: card declined"}},
+		{query: "payment OR alice", want: []string{"payment ok", "payment failed: card declined", "login ok for user alice"}},
+		{query: "failed", filter: Filter{Name: "auth"}, want: []string{"failed login, failed twice", "login failed for user bob"}},
+		{query: "nothing", want: []string{}},
+	}
+
+	for storeName, store := range testStores(t) {
+		for _, tt := range tests {
+			t.Run(storeName+"/"+tt.query, func(t *testing.T) {
+				query, err := ParseSearch(tt.query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				query.Filter = tt.filter
+				if tt.limit > 0 {
+					query.Limit = tt.limit
+				}
+
+				results, err := store.Search(query)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got := make([]string, len(results))
+				for i, result := range results {
+					got[i] = result.Entry.Data
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("got %q, want %q", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestSearchSnippet(t *testing.T) {
+	query, err := ParseSearch(`"card declined"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := NewMemoryStore().Search(query)
+	if err != nil || len(results) != 0 {
+		t.Fatalf("empty store: %v, %v", results, err)
+	}
+
+	store := NewMemoryStore()
+	store.Insert(context.Background(), LogEntry{Name: "x", Data: "<b>payment</b> failed: card declined"})
+	results, err = store.Search(query)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("results = %v, %v", results, err)
+	}
+
+	want := "&lt;b&gt;payment&lt;/b&gt; failed: <mark>card declined</mark>"
+	if results[0].Snippet != want {
+		t.Errorf("snippet = %q, want %q", results[0].Snippet, want)
+	}
+}
+
+func TestParseSearchInvalid(t *testing.T) {
+	for _, text := range []string{"", "   ", "-bob", "NOT bob", "a OR -b"} {
+		_, err := ParseSearch(text)
+		if err == nil {
+			t.Errorf("ParseSearch(%q) succeeded", text)
+		}
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	day := func(days int) *time.Time {
+		t := base.Add(time.Duration(days) * 24 * time.Hour)
+		return &t
+	}
+
+	tests := []struct {
+		name  string
+		query AggregateQuery
+		want  []AggregateRow
+	}{
+		{
+			name:  "total",
+			query: AggregateQuery{},
+			want:  []AggregateRow{{Count: 5}},
+		},
+		{
+			name:  "by name",
+			query: AggregateQuery{GroupBy: []string{"name"}},
+			want:  []AggregateRow{{Name: "auth", Count: 3}, {Name: "billing", Count: 2}},
+		},
+		{
+			name:  "by severity within a name",
+			query: AggregateQuery{Filter: Filter{Name: "billing"}, GroupBy: []string{"severity"}},
+			want:  []AggregateRow{{Severity: "ERROR", Count: 1}, {Severity: "INFO", Count: 1}},
+		},
+		{
+			name:  "daily buckets",
+			query: AggregateQuery{Bucket: 24 * time.Hour},
+			want:  []AggregateRow{{Bucket: day(0), Count: 2}, {Bucket: day(1), Count: 3}},
+		},
+		{
+			name:  "daily buckets by service",
+			query: AggregateQuery{Bucket: 24 * time.Hour, GroupBy: []string{"service"}},
+			want: []AggregateRow{
+				{Bucket: day(0), Service: "api", Count: 2},
+				{Bucket: day(1), Service: "web", Count: 1},
+				{Bucket: day(1), Service: "worker", Count: 2},
+			},
+		},
+		{
+			name:  "top names",
+			query: AggregateQuery{GroupBy: []string{"name"}, Limit: 1},
+			want:  []AggregateRow{{Name: "auth", Count: 3}},
+		},
+	}
+
+	for storeName, store := range testStores(t) {
+		for _, tt := range tests {
+			t.Run(storeName+"/"+tt.name, func(t *testing.T) {
+				err := tt.query.Validate()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				rows, err := store.Aggregate(tt.query)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(rows, tt.want) {
+					t.Errorf("got %+v, want %+v", rows, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestAggregateInvalid(t *testing.T) {
+	tests := []AggregateQuery{
+		{GroupBy: []string{"data"}},
+		{Bucket: time.Microsecond},
+		{Bucket: -time.Hour},
+	}
+
+	for _, query := range tests {
+		if query.Validate() == nil {
+			t.Errorf("%+v validated", query)
+		}
+	}
+}
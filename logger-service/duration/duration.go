@@ -0,0 +1,24 @@
+package duration
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parse accepts everything time.ParseDuration does plus a "d" suffix for days.
+func Parse(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,174 @@
+package otlp
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/danilobml/logger-service/data"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+const defaultName = "otlp"
+
+// Convert maps an export request onto log entries: the scope name becomes
+// the entry name, service.name the service, and resource attributes are
+// stored with a "resource." prefix next to the record's own attributes.
+func Convert(req *collogspb.ExportLogsServiceRequest, now time.Time) []data.LogEntry {
+	var entries []data.LogEntry
+
+	for _, resourceLogs := range req.GetResourceLogs() {
+		resourceAttributes := map[string]string{}
+		service := ""
+		for _, kv := range resourceLogs.GetResource().GetAttributes() {
+			value := stringValue(kv.GetValue())
+			if kv.GetKey() == "service.name" {
+				service = value
+			}
+			resourceAttributes["resource."+kv.GetKey()] = value
+		}
+
+		for _, scopeLogs := range resourceLogs.GetScopeLogs() {
+			name := scopeLogs.GetScope().GetName()
+			if name == "" {
+				name = defaultName
+			}
+
+			for _, record := range scopeLogs.GetLogRecords() {
+				entries = append(entries, convertRecord(record, name, service, resourceAttributes, now))
+			}
+		}
+	}
+
+	return entries
+}
+
+func convertRecord(record *logspb.LogRecord, name, service string, resourceAttributes map[string]string, now time.Time) data.LogEntry {
+	attributes := make(map[string]string, len(resourceAttributes)+len(record.GetAttributes()))
+	for k, v := range resourceAttributes {
+		attributes[k] = v
+	}
+	for _, kv := range record.GetAttributes() {
+		attributes[kv.GetKey()] = stringValue(kv.GetValue())
+	}
+	if record.GetEventName() != "" {
+		attributes["event.name"] = record.GetEventName()
+	}
+
+	entry := data.LogEntry{
+		Name:       name,
+		Data:       stringValue(record.GetBody()),
+		Severity:   severity(record),
+		Service:    service,
+		Attributes: attributes,
+		CreatedAt:  now,
+	}
+
+	if ts := record.GetTimeUnixNano(); ts > 0 {
+		entry.CreatedAt = time.Unix(0, int64(ts)).UTC()
+	} else if ts := record.GetObservedTimeUnixNano(); ts > 0 {
+		entry.CreatedAt = time.Unix(0, int64(ts)).UTC()
+	}
+
+	if id := record.GetTraceId(); len(id) > 0 && !allZero(id) {
+		entry.TraceID = hex.EncodeToString(id)
+	}
+	if id := record.GetSpanId(); len(id) > 0 && !allZero(id) {
+		entry.SpanID = hex.EncodeToString(id)
+	}
+
+	if len(entry.Attributes) == 0 {
+		entry.Attributes = nil
+	}
+
+	return entry
+}
+
+// severity prefers the severity number, mapped onto the same names the
+// syslog listener uses, and falls back to the free-form severity text.
+func severity(record *logspb.LogRecord) string {
+	switch n := record.GetSeverityNumber(); {
+	case n >= logspb.SeverityNumber_SEVERITY_NUMBER_FATAL:
+		return "FATAL"
+	case n >= logspb.SeverityNumber_SEVERITY_NUMBER_ERROR:
+		return "ERROR"
+	case n >= logspb.SeverityNumber_SEVERITY_NUMBER_WARN:
+		return "WARNING"
+	case n >= logspb.SeverityNumber_SEVERITY_NUMBER_INFO:
+		return "INFO"
+	case n >= logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG:
+		return "DEBUG"
+	case n >= logspb.SeverityNumber_SEVERITY_NUMBER_TRACE:
+		return "TRACE"
+	}
+
+	return record.GetSeverityText()
+}
+
+func stringValue(value *commonpb.AnyValue) string {
+	if value == nil {
+		return ""
+	}
+
+	switch v := value.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return v.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return strconv.FormatBool(v.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		return strconv.FormatInt(v.IntValue, 10)
+	case *commonpb.AnyValue_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'g', -1, 64)
+	case *commonpb.AnyValue_BytesValue:
+		return hex.EncodeToString(v.BytesValue)
+	}
+
+	out, err := json.Marshal(plainValue(value))
+	if err != nil {
+		return ""
+	}
+
+	return string(out)
+}
+
+func plainValue(value *commonpb.AnyValue) any {
+	switch v := value.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return v.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return v.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return v.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *commonpb.AnyValue_BytesValue:
+		return hex.EncodeToString(v.BytesValue)
+	case *commonpb.AnyValue_ArrayValue:
+		values := []any{}
+		for _, item := range v.ArrayValue.GetValues() {
+			values = append(values, plainValue(item))
+		}
+		return values
+	case *commonpb.AnyValue_KvlistValue:
+		values := map[string]any{}
+		for _, kv := range v.KvlistValue.GetValues() {
+			values[kv.GetKey()] = plainValue(kv.GetValue())
+		}
+		return values
+	}
+
+	return nil
+}
+
+func allZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+
+	return true
+}
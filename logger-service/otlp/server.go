@@ -0,0 +1,188 @@
+package otlp
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/danilobml/logger-service/data"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const maxBodySize = 16 * 1024 * 1024
+
+type Handler func(ctx context.Context, entry data.LogEntry) error
+
+// LogsServer implements the OTLP/gRPC logs service and the OTLP/HTTP
+// /v1/logs endpoint on top of the same handler.
+type LogsServer struct {
+	collogspb.UnimplementedLogsServiceServer
+	Handler Handler
+}
+
+func (s *LogsServer) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	res, err := s.export(ctx, req)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	return res, nil
+}
+
+func (s *LogsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	body, err := readBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := &collogspb.ExportLogsServiceRequest{}
+	switch contentType {
+	case "application/x-protobuf":
+		err = proto.Unmarshal(body, req)
+	case "application/json":
+		err = unmarshalJSON(body, req)
+	default:
+		http.Error(w, "unsupported content type: "+contentType, http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		http.Error(w, "invalid export request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := s.export(r.Context(), req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	var out []byte
+	if contentType == "application/json" {
+		out, err = protojson.Marshal(res)
+	} else {
+		out, err = proto.Marshal(res)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(out)
+}
+
+// export stores every record it can; if none could be stored the request
+// fails so the client retries, otherwise failures are reported as partial success.
+func (s *LogsServer) export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	entries := Convert(req, time.Now())
+
+	var rejected int64
+	var lastErr error
+	for _, entry := range entries {
+		err := s.Handler(ctx, entry)
+		if err != nil {
+			rejected++
+			lastErr = err
+		}
+	}
+
+	if len(entries) > 0 && rejected == int64(len(entries)) {
+		log.Println("error storing otlp logs: ", lastErr)
+		return nil, lastErr
+	}
+
+	res := &collogspb.ExportLogsServiceResponse{}
+	if rejected > 0 {
+		res.PartialSuccess = &collogspb.ExportLogsPartialSuccess{
+			RejectedLogRecords: rejected,
+			ErrorMessage:       lastErr.Error(),
+		}
+	}
+
+	return res, nil
+}
+
+func readBody(r *http.Request) ([]byte, error) {
+	var reader io.Reader = http.MaxBytesReader(nil, r.Body, maxBodySize)
+
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = io.LimitReader(gz, maxBodySize)
+	}
+
+	return io.ReadAll(reader)
+}
+
+// unmarshalJSON handles the one place where OTLP/JSON differs from protojson:
+// trace and span ids are hex strings instead of base64.
+func unmarshalJSON(body []byte, req *collogspb.ExportLogsServiceRequest) error {
+	var raw map[string]any
+	err := json.Unmarshal(body, &raw)
+	if err != nil {
+		return err
+	}
+
+	for _, rl := range list(raw, "resourceLogs", "resource_logs") {
+		for _, sl := range list(rl, "scopeLogs", "scope_logs") {
+			for _, lr := range list(sl, "logRecords", "log_records") {
+				for _, key := range []string{"traceId", "trace_id", "spanId", "span_id"} {
+					id, ok := lr[key].(string)
+					if !ok || id == "" {
+						continue
+					}
+
+					decoded, err := hex.DecodeString(id)
+					if err != nil {
+						return fmt.Errorf("invalid %s: %w", key, err)
+					}
+					lr[key] = base64.StdEncoding.EncodeToString(decoded)
+				}
+			}
+		}
+	}
+
+	body, err = json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, req)
+}
+
+func list(m map[string]any, keys ...string) []map[string]any {
+	var items []map[string]any
+
+	for _, key := range keys {
+		values, ok := m[key].([]any)
+		if !ok {
+			continue
+		}
+		for _, v := range values {
+			if item, ok := v.(map[string]any); ok {
+				items = append(items, item)
+			}
+		}
+	}
+
+	return items
+}
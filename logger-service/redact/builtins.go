@@ -0,0 +1,63 @@
+package redact
+
+import (
+	"regexp"
+)
+
+var builtins = map[string][]pattern{
+	"email": {
+		mask(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`, "[EMAIL]"),
+	},
+	"card": {{
+		re: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		replace: func(src string, loc []int) (string, bool) {
+			return "[CARD]", luhn(src[loc[0]:loc[1]])
+		},
+	}},
+	"token": {
+		mask(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`, "[TOKEN]"),
+		mask(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`, "Bearer [TOKEN]"),
+		{
+			re: regexp.MustCompile(`(?i)\b(password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key)(["']?\s*[:=]\s*["']?)([^\s"'&,;]+)`),
+			replace: func(src string, loc []int) (string, bool) {
+				return src[loc[2]:loc[3]] + src[loc[4]:loc[5]] + "[REDACTED]", true
+			},
+		},
+	},
+}
+
+func mask(expr, replacement string) pattern {
+	return pattern{
+		re: regexp.MustCompile(expr),
+		replace: func(string, []int) (string, bool) {
+			return replacement, true
+		},
+	}
+}
+
+// luhn reports whether the digits in s form a valid card number.
+func luhn(s string) bool {
+	sum := 0
+	digits := 0
+	double := false
+
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+		digits++
+	}
+
+	return digits >= 13 && digits <= 19 && sum%10 == 0
+}
@@ -0,0 +1,199 @@
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/danilobml/logger-service/data"
+)
+
+const fieldMask = "[REDACTED]"
+
+// pattern replaces each match of re with the result of replace, which gets
+// the source string and the submatch offsets; returning false keeps the match.
+type pattern struct {
+	re      *regexp.Regexp
+	replace func(src string, loc []int) (string, bool)
+}
+
+type rule struct {
+	name     string
+	patterns []pattern
+}
+
+// CustomRule is a user supplied regex rule. Replacement may use $1-style
+// references to capture groups and defaults to "[REDACTED]".
+type CustomRule struct {
+	Name        string `json:"name"`
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+}
+
+type Redactor struct {
+	rules  []rule
+	fields map[string]bool
+
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// New builds a redactor from built-in detector names (email, card, token),
+// custom regex rules and attribute keys whose values are masked entirely.
+func New(detectors []string, custom []CustomRule, fields []string) (*Redactor, error) {
+	r := &Redactor{
+		fields: map[string]bool{},
+		counts: map[string]int64{},
+	}
+
+	for _, name := range detectors {
+		detector, ok := builtins[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown redaction detector: %s", name)
+		}
+		r.rules = append(r.rules, rule{name: name, patterns: detector})
+	}
+
+	for _, c := range custom {
+		if c.Name == "" {
+			return nil, fmt.Errorf("redaction rule %q needs a name", c.Pattern)
+		}
+
+		re, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction rule %s: %w", c.Name, err)
+		}
+
+		replacement := c.Replacement
+		if replacement == "" {
+			replacement = fieldMask
+		}
+
+		r.rules = append(r.rules, rule{
+			name: c.Name,
+			patterns: []pattern{{
+				re: re,
+				replace: func(src string, loc []int) (string, bool) {
+					return string(re.ExpandString(nil, replacement, src, loc)), true
+				},
+			}},
+		})
+	}
+
+	for _, field := range fields {
+		if field = strings.TrimSpace(field); field != "" {
+			r.fields[strings.ToLower(field)] = true
+		}
+	}
+
+	return r, nil
+}
+
+func ParseCustomRules(raw string) ([]CustomRule, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var rules []CustomRule
+	err := json.Unmarshal([]byte(raw), &rules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redaction rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// Apply redacts the entry's data and attribute values in place.
+func (r *Redactor) Apply(entry *data.LogEntry) {
+	counts := map[string]int64{}
+
+	entry.Data = r.redact(entry.Data, counts)
+
+	if len(entry.Attributes) > 0 {
+		attributes := make(map[string]string, len(entry.Attributes))
+		for key, value := range entry.Attributes {
+			if r.fields[strings.ToLower(key)] {
+				attributes[key] = fieldMask
+				counts["field:"+strings.ToLower(key)]++
+				continue
+			}
+			attributes[key] = r.redact(value, counts)
+		}
+		entry.Attributes = attributes
+	}
+
+	if len(counts) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name, n := range counts {
+		r.counts[name] += n
+	}
+}
+
+func (r *Redactor) redact(value string, counts map[string]int64) string {
+	for _, rule := range r.rules {
+		for _, p := range rule.patterns {
+			value = replaceAll(p, value, func() {
+				counts[rule.name]++
+			})
+		}
+	}
+
+	return value
+}
+
+func replaceAll(p pattern, value string, hit func()) string {
+	matches := p.re.FindAllStringSubmatchIndex(value, -1)
+	if len(matches) == 0 {
+		return value
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range matches {
+		replacement, ok := p.replace(value, loc)
+		if !ok {
+			continue
+		}
+
+		b.WriteString(value[last:loc[0]])
+		b.WriteString(replacement)
+		last = loc[1]
+		hit()
+	}
+	b.WriteString(value[last:])
+
+	return b.String()
+}
+
+func (r *Redactor) Counts() map[string]int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := make(map[string]int64, len(r.counts))
+	for name, n := range r.counts {
+		counts[name] = n
+	}
+
+	return counts
+}
+
+func (r *Redactor) RuleNames() []string {
+	var names []string
+	for _, rule := range r.rules {
+		names = append(names, rule.name)
+	}
+	for field := range r.fields {
+		names = append(names, "field:"+field)
+	}
+	sort.Strings(names)
+
+	return names
+}
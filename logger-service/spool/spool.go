@@ -0,0 +1,213 @@
+package spool
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/danilobml/logger-service/data"
+)
+
+const (
+	spoolFile  = "spool.jsonl"
+	offsetFile = "spool.offset"
+	batchSize  = 100
+)
+
+var ErrFull = errors.New("spool is full")
+
+// Spool is a bounded FIFO of log entries kept on disk while the store is
+// unavailable. Entries are appended to a JSON lines file and replayed from
+// a persisted byte offset, so a restart resumes where replay left off.
+type Spool struct {
+	mu     sync.Mutex
+	dir    string
+	max    int
+	file   *os.File
+	depth  int
+	offset int64
+}
+
+func Open(dir string, max int) (*Spool, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Spool{dir: dir, max: max}
+
+	content, err := os.ReadFile(s.path(offsetFile))
+	if err == nil {
+		s.offset, _ = strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+	}
+
+	s.file, err = os.OpenFile(s.path(spoolFile), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	s.depth, err = s.count()
+	if err != nil {
+		s.file.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Spool) Depth() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.depth
+}
+
+func (s *Spool) Push(entry data.LogEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.max > 0 && s.depth >= s.max {
+		return ErrFull
+	}
+
+	_, err = s.file.Write(append(line, '\n'))
+	if err != nil {
+		return err
+	}
+
+	err = s.file.Sync()
+	if err != nil {
+		return err
+	}
+
+	s.depth++
+
+	return nil
+}
+
+// Drain replays spooled entries in order until the spool is empty or insert
+// fails, returning how many entries were replayed.
+func (s *Spool) Drain(insert func(entry data.LogEntry) error) (int, error) {
+	replayed := 0
+
+	for {
+		entries, sizes, err := s.peek(batchSize)
+		if err != nil {
+			return replayed, err
+		}
+		if len(entries) == 0 {
+			return replayed, s.reset()
+		}
+
+		for i, entry := range entries {
+			err := insert(entry)
+			if err != nil {
+				return replayed, err
+			}
+
+			err = s.advance(sizes[i])
+			if err != nil {
+				return replayed, err
+			}
+			replayed++
+		}
+	}
+}
+
+func (s *Spool) peek(n int) ([]data.LogEntry, []int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reader := bufio.NewReader(io.NewSectionReader(s.file, s.offset, 1<<62))
+
+	var entries []data.LogEntry
+	var sizes []int64
+	for len(entries) < n {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// a partial trailing line is left for the next pass
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var entry data.LogEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			// skip lines that cannot be decoded rather than blocking replay forever
+			s.offset += int64(len(line))
+			s.depth--
+			continue
+		}
+
+		entries = append(entries, entry)
+		sizes = append(sizes, int64(len(line)))
+	}
+
+	return entries, sizes, nil
+}
+
+func (s *Spool) advance(size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.offset += size
+	s.depth--
+
+	return os.WriteFile(s.path(offsetFile), []byte(strconv.FormatInt(s.offset, 10)), 0644)
+}
+
+// reset truncates the spool once everything in it has been replayed.
+func (s *Spool) reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.depth > 0 {
+		return nil
+	}
+
+	err := s.file.Truncate(0)
+	if err != nil {
+		return err
+	}
+
+	s.offset = 0
+	s.depth = 0
+
+	return os.WriteFile(s.path(offsetFile), []byte("0"), 0644)
+}
+
+func (s *Spool) count() (int, error) {
+	reader := bufio.NewReader(io.NewSectionReader(s.file, s.offset, 1<<62))
+
+	count := 0
+	for {
+		_, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return count, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		count++
+	}
+}
+
+func (s *Spool) path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+func (s *Spool) Close() error {
+	return s.file.Close()
+}
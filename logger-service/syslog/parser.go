@@ -0,0 +1,220 @@
+package syslog
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/danilobml/logger-service/data"
+)
+
+var ErrInvalidMessage = errors.New("invalid syslog message")
+
+var facilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var severities = []string{
+	"EMERGENCY", "ALERT", "CRITICAL", "ERROR", "WARNING", "NOTICE", "INFO", "DEBUG",
+}
+
+// Parse turns an RFC 5424 or RFC 3164 message into a log entry named "syslog",
+// with the app-name as service and facility, host and ids as attributes.
+func Parse(raw string, now time.Time) (data.LogEntry, error) {
+	raw = strings.TrimRight(raw, "\r\n\x00")
+
+	priority, rest, err := parsePriority(raw)
+	if err != nil {
+		return data.LogEntry{}, err
+	}
+
+	entry := data.LogEntry{
+		Name:       "syslog",
+		Severity:   severities[priority%8],
+		Attributes: map[string]string{"facility": facilities[priority/8]},
+	}
+
+	if len(rest) > 1 && rest[0] >= '1' && rest[0] <= '9' && rest[1] == ' ' {
+		err = parse5424(rest[2:], &entry)
+	} else {
+		parse3164(rest, now, &entry)
+	}
+	if err != nil {
+		return data.LogEntry{}, err
+	}
+
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = now
+	}
+
+	return entry, nil
+}
+
+func parsePriority(raw string) (int, string, error) {
+	if !strings.HasPrefix(raw, "<") {
+		return 0, "", ErrInvalidMessage
+	}
+
+	end := strings.IndexByte(raw, '>')
+	if end < 2 || end > 4 {
+		return 0, "", ErrInvalidMessage
+	}
+
+	priority, err := strconv.Atoi(raw[1:end])
+	if err != nil || priority < 0 || priority > 191 {
+		return 0, "", ErrInvalidMessage
+	}
+
+	return priority, raw[end+1:], nil
+}
+
+// parse5424 reads TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG].
+func parse5424(rest string, entry *data.LogEntry) error {
+	fields := make([]string, 5)
+	for i := range fields {
+		var ok bool
+		fields[i], rest, ok = strings.Cut(rest, " ")
+		if !ok && i < len(fields)-1 {
+			return ErrInvalidMessage
+		}
+	}
+
+	if fields[0] != "-" {
+		t, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return ErrInvalidMessage
+		}
+		entry.CreatedAt = t
+	}
+
+	setAttribute(entry, "hostname", fields[1])
+	if fields[2] != "-" {
+		entry.Service = fields[2]
+	}
+	setAttribute(entry, "proc_id", fields[3])
+	setAttribute(entry, "msg_id", fields[4])
+
+	if strings.HasPrefix(rest, "-") {
+		rest = strings.TrimPrefix(rest[1:], " ")
+	} else if strings.HasPrefix(rest, "[") {
+		var err error
+		rest, err = parseStructuredData(rest, entry)
+		if err != nil {
+			return err
+		}
+		rest = strings.TrimPrefix(rest, " ")
+	}
+
+	entry.Data = strings.TrimPrefix(rest, "\ufeff")
+
+	return nil
+}
+
+func parseStructuredData(rest string, entry *data.LogEntry) (string, error) {
+	for strings.HasPrefix(rest, "[") {
+		end := -1
+		inQuotes := false
+		for i := 1; i < len(rest); i++ {
+			switch {
+			case rest[i] == '\\' && inQuotes:
+				i++
+			case rest[i] == '"':
+				inQuotes = !inQuotes
+			case rest[i] == ']' && !inQuotes:
+				end = i
+			}
+			if end >= 0 {
+				break
+			}
+		}
+		if end < 0 {
+			return "", ErrInvalidMessage
+		}
+
+		element := rest[1:end]
+		rest = rest[end+1:]
+
+		id, params, _ := strings.Cut(element, " ")
+		for params != "" {
+			name, value, ok := strings.Cut(params, `="`)
+			if !ok {
+				return "", ErrInvalidMessage
+			}
+
+			var b strings.Builder
+			i := 0
+			for ; i < len(value) && value[i] != '"'; i++ {
+				if value[i] == '\\' && i+1 < len(value) {
+					i++
+				}
+				b.WriteByte(value[i])
+			}
+			if i >= len(value) {
+				return "", ErrInvalidMessage
+			}
+
+			setAttribute(entry, "sd."+id+"."+strings.TrimSpace(name), b.String())
+			params = strings.TrimLeft(value[i+1:], " ")
+		}
+	}
+
+	return rest, nil
+}
+
+// parse3164 reads the BSD format "Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG",
+// falling back to treating everything as the message.
+func parse3164(rest string, now time.Time, entry *data.LogEntry) {
+	if len(rest) >= 16 && rest[15] == ' ' {
+		t, err := time.ParseInLocation(time.Stamp, rest[:15], now.Location())
+		if err == nil {
+			t = t.AddDate(now.Year(), 0, 0)
+			// messages from late December that arrive in January belong to last year
+			if t.After(now.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			entry.CreatedAt = t
+			rest = rest[16:]
+
+			hostname, remaining, ok := strings.Cut(rest, " ")
+			if ok {
+				setAttribute(entry, "hostname", hostname)
+				rest = remaining
+			}
+		}
+	}
+
+	tagEnd := strings.IndexFunc(rest, func(r rune) bool {
+		return !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.' || r == '/')
+	})
+	if tagEnd > 0 && tagEnd <= 48 {
+		tag := rest[:tagEnd]
+		remaining := rest[tagEnd:]
+
+		if strings.HasPrefix(remaining, "[") {
+			pid, after, ok := strings.Cut(remaining[1:], "]")
+			if ok {
+				setAttribute(entry, "proc_id", pid)
+				remaining = after
+			}
+		}
+
+		if strings.HasPrefix(remaining, ":") {
+			entry.Service = tag
+			rest = strings.TrimPrefix(remaining[1:], " ")
+		}
+	}
+
+	entry.Data = rest
+}
+
+func setAttribute(entry *data.LogEntry, key, value string) {
+	if value == "" || value == "-" {
+		return
+	}
+
+	entry.Attributes[key] = value
+}
@@ -0,0 +1,145 @@
+package syslog
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		raw        string
+		severity   string
+		service    string
+		data       string
+		createdAt  time.Time
+		attributes map[string]string
+	}{
+		{
+			name:      "rfc5424",
+			raw:       "<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - 'su root' failed on /dev/pts/8",
+			severity:  "CRITICAL",
+			service:   "su",
+			data:      "'su root' failed on /dev/pts/8",
+			createdAt: time.Date(2003, time.October, 11, 22, 14, 15, 3000000, time.UTC),
+			attributes: map[string]string{
+				"facility": "auth",
+				"hostname": "mymachine.example.com",
+				"msg_id":   "ID47",
+			},
+		},
+		{
+			name:      "rfc5424 with structured data and bom",
+			raw:       `<165>1 2003-10-11T22:14:15.003Z host evntslog 42 ID47 [exampleSDID@32473 iut="3" eventSource="App\"lication"][meta seq="1"] ` + "\ufeff" + "An application event",
+			severity:  "NOTICE",
+			service:   "evntslog",
+			data:      "An application event",
+			createdAt: time.Date(2003, time.October, 11, 22, 14, 15, 3000000, time.UTC),
+			attributes: map[string]string{
+				"facility":                         "local4",
+				"hostname":                         "host",
+				"proc_id":                          "42",
+				"msg_id":                           "ID47",
+				"sd.exampleSDID@32473.iut":         "3",
+				"sd.exampleSDID@32473.eventSource": `App"lication`,
+				"sd.meta.seq":                      "1",
+			},
+		},
+		{
+			name:       "rfc5424 with nil values",
+			raw:        "<14>1 - - - - - -",
+			severity:   "INFO",
+			createdAt:  now,
+			attributes: map[string]string{"facility": "user"},
+		},
+		{
+			name:      "rfc3164",
+			raw:       "<34>Oct 11 22:14:15 mymachine su[230]: 'su root' failed",
+			severity:  "CRITICAL",
+			service:   "su",
+			data:      "'su root' failed",
+			createdAt: time.Date(2023, time.October, 11, 22, 14, 15, 0, time.UTC),
+			attributes: map[string]string{
+				"facility": "auth",
+				"hostname": "mymachine",
+				"proc_id":  "230",
+			},
+		},
+		{
+			name:      "rfc3164 from earlier this year",
+			raw:       "<13>Mar  9 08:00:00 web nginx: started",
+			severity:  "NOTICE",
+			service:   "nginx",
+			data:      "started",
+			createdAt: time.Date(2024, time.March, 9, 8, 0, 0, 0, time.UTC),
+			attributes: map[string]string{
+				"facility": "user",
+				"hostname": "web",
+			},
+		},
+		{
+			name:       "rfc3164 without header",
+			raw:        "<13>just a message\n",
+			severity:   "NOTICE",
+			data:       "just a message",
+			createdAt:  now,
+			attributes: map[string]string{"facility": "user"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, err := Parse(tt.raw, now)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+
+			if entry.Name != "syslog" {
+				t.Errorf("name = %q, want syslog", entry.Name)
+			}
+			if entry.Severity != tt.severity {
+				t.Errorf("severity = %q, want %q", entry.Severity, tt.severity)
+			}
+			if entry.Service != tt.service {
+				t.Errorf("service = %q, want %q", entry.Service, tt.service)
+			}
+			if entry.Data != tt.data {
+				t.Errorf("data = %q, want %q", entry.Data, tt.data)
+			}
+			if !entry.CreatedAt.Equal(tt.createdAt) {
+				t.Errorf("created_at = %s, want %s", entry.CreatedAt, tt.createdAt)
+			}
+			if !reflect.DeepEqual(entry.Attributes, tt.attributes) {
+				t.Errorf("attributes = %v, want %v", entry.Attributes, tt.attributes)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"no priority", "hello"},
+		{"unterminated priority", "<34 hello"},
+		{"priority out of range", "<192>1 - - - - - -"},
+		{"non numeric priority", "<ab>hello"},
+		{"rfc5424 missing fields", "<34>1 2003-10-11T22:14:15Z host"},
+		{"rfc5424 bad timestamp", "<34>1 yesterday host app - - - msg"},
+		{"rfc5424 unterminated structured data", `<34>1 - host app - - [id a="1" msg`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.raw, time.Now())
+			if !errors.Is(err, ErrInvalidMessage) {
+				t.Errorf("err = %v, want %v", err, ErrInvalidMessage)
+			}
+		})
+	}
+}
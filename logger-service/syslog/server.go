@@ -0,0 +1,150 @@
+package syslog
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/danilobml/logger-service/data"
+)
+
+const maxMessageSize = 64 * 1024
+
+type Handler func(entry data.LogEntry) error
+
+type Server struct {
+	Handler Handler
+}
+
+func (s *Server) ListenUDP(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	log.Printf("Syslog UDP listener on %s", addr)
+
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			continue
+		}
+
+		s.handle(string(buf[:n]))
+	}
+}
+
+func (s *Server) ListenTCP(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	log.Printf("Syslog TCP listener on %s", addr)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			continue
+		}
+
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn accepts both octet-counted (RFC 6587 "LEN MSG") and
+// newline-delimited framing, decided per message. A frame longer than
+// maxMessageSize drops the connection.
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReaderSize(conn, maxMessageSize)
+	for {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
+
+		first, err := reader.Peek(1)
+		if err != nil {
+			return
+		}
+
+		var message string
+		if first[0] >= '0' && first[0] <= '9' {
+			message, err = readOctetCounted(reader)
+		} else {
+			message, err = readLine(reader, '\n')
+			if errors.Is(err, io.EOF) && message != "" {
+				err = nil
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Println("error reading syslog message: ", err)
+			}
+			return
+		}
+
+		s.handle(message)
+	}
+}
+
+func readOctetCounted(reader *bufio.Reader) (string, error) {
+	length, err := readLine(reader, ' ')
+	if err != nil {
+		return "", err
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(length))
+	if err != nil || n <= 0 || n > maxMessageSize {
+		return "", errors.New("invalid syslog frame length: " + strings.TrimSpace(length))
+	}
+
+	buf := make([]byte, n)
+	_, err = io.ReadFull(reader, buf)
+	if err != nil {
+		return "", err
+	}
+
+	return string(buf), nil
+}
+
+// readLine reads up to and including delim, at most the reader's buffer
+// size of maxMessageSize.
+func readLine(reader *bufio.Reader, delim byte) (string, error) {
+	line, err := reader.ReadSlice(delim)
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return "", errors.New("syslog message exceeds " + strconv.Itoa(maxMessageSize) + " bytes")
+	}
+
+	return string(line), err
+}
+
+func (s *Server) handle(raw string) {
+	if strings.TrimSpace(raw) == "" {
+		return
+	}
+
+	entry, err := Parse(raw, time.Now())
+	if err != nil {
+		log.Printf("dropping syslog message %q: %s", raw, err)
+		return
+	}
+
+	err = s.Handler(entry)
+	if err != nil {
+		log.Println("error storing syslog message: ", err)
+	}
+}
@@ -0,0 +1,201 @@
+package throttle
+
+import (
+	"crypto/sha256"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/danilobml/logger-service/data"
+)
+
+// missingTimeout is how long Flush keeps retrying repeat counts for an
+// entry the store doesn't have yet, e.g. while it waits in the spool.
+const missingTimeout = time.Hour
+
+// Deduper collapses identical (name, data) entries seen within a window
+// into the first one stored. Repeats are counted in memory and written back
+// to that entry in batches by Flush.
+type Deduper struct {
+	window time.Duration
+	store  data.LogStore
+
+	mu        sync.Mutex
+	open      map[[32]byte]*occurrence
+	closed    []*occurrence
+	collapsed int64
+}
+
+type occurrence struct {
+	key       [32]byte
+	id        string
+	repeat    int64
+	firstSeen time.Time
+	lastSeen  time.Time
+	dirty     bool
+
+	missingSince time.Time
+}
+
+type DedupStats struct {
+	Window    string `json:"window"`
+	Open      int    `json:"open"`
+	Collapsed int64  `json:"collapsed"`
+}
+
+func NewDeduper(window time.Duration, store data.LogStore) *Deduper {
+	return &Deduper{
+		window: window,
+		store:  store,
+		open:   map[[32]byte]*occurrence{},
+	}
+}
+
+func key(entry *data.LogEntry) [32]byte {
+	return sha256.Sum256([]byte(entry.Name + "\x00" + entry.Data))
+}
+
+// Seen reports whether the entry repeats one stored within the window. If
+// not, the entry becomes the one later repeats collapse into.
+func (d *Deduper) Seen(entry *data.LogEntry) bool {
+	k := key(entry)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if o, ok := d.open[k]; ok && entry.CreatedAt.Sub(o.firstSeen) < d.window {
+		o.repeat++
+		if entry.CreatedAt.After(o.lastSeen) {
+			o.lastSeen = entry.CreatedAt
+		}
+		o.dirty = true
+		d.collapsed++
+		return true
+	}
+
+	if o, ok := d.open[k]; ok && o.dirty {
+		d.closed = append(d.closed, o)
+	}
+
+	d.open[k] = &occurrence{
+		key:       k,
+		id:        entry.ID,
+		repeat:    1,
+		firstSeen: entry.CreatedAt,
+		lastSeen:  entry.CreatedAt,
+	}
+
+	return false
+}
+
+// Forget drops an entry registered by Seen that could not be stored.
+func (d *Deduper) Forget(entry *data.LogEntry) {
+	k := key(entry)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if o, ok := d.open[k]; ok && o.id == entry.ID {
+		delete(d.open, k)
+	}
+}
+
+// Flush writes pending repeat counts to the store and closes windows that
+// have ended.
+func (d *Deduper) Flush(now time.Time) error {
+	d.mu.Lock()
+	var updates []data.RepeatUpdate
+	var flushed []*occurrence
+	pending := d.closed
+	d.closed = nil
+	for k, o := range d.open {
+		if o.dirty {
+			pending = append(pending, o)
+			continue
+		}
+		if now.Sub(o.firstSeen) >= d.window {
+			delete(d.open, k)
+		}
+	}
+	for _, o := range pending {
+		updates = append(updates, data.RepeatUpdate{
+			ID:        o.id,
+			Repeat:    o.repeat,
+			FirstSeen: o.firstSeen,
+			LastSeen:  o.lastSeen,
+		})
+		flushed = append(flushed, o)
+		o.dirty = false
+	}
+	d.mu.Unlock()
+
+	if len(updates) == 0 {
+		return nil
+	}
+
+	missing, err := d.store.UpdateRepeats(updates)
+	if err != nil {
+		d.mu.Lock()
+		for _, o := range flushed {
+			d.retry(o)
+		}
+		d.mu.Unlock()
+		return err
+	}
+
+	if len(missing) > 0 {
+		missingIDs := make(map[string]bool, len(missing))
+		for _, id := range missing {
+			missingIDs[id] = true
+		}
+
+		d.mu.Lock()
+		for _, o := range flushed {
+			if !missingIDs[o.id] {
+				continue
+			}
+			if o.missingSince.IsZero() {
+				o.missingSince = now
+			}
+			if now.Sub(o.missingSince) > missingTimeout {
+				log.Printf("dropping %d repeats of entry %s: not in the store after %s", o.repeat, o.id, missingTimeout)
+				continue
+			}
+			d.retry(o)
+		}
+		d.mu.Unlock()
+	}
+
+	return nil
+}
+
+// retry marks an occurrence to be written by the next Flush; callers hold
+// d.mu.
+func (d *Deduper) retry(o *occurrence) {
+	o.dirty = true
+	if d.open[o.key] != o {
+		d.closed = append(d.closed, o)
+	}
+}
+
+func (d *Deduper) Run(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+
+		err := d.Flush(time.Now())
+		if err != nil {
+			log.Println("error flushing repeat counts: ", err)
+		}
+	}
+}
+
+func (d *Deduper) Stats() DedupStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return DedupStats{
+		Window:    d.window.String(),
+		Open:      len(d.open),
+		Collapsed: d.collapsed,
+	}
+}
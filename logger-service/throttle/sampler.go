@@ -0,0 +1,104 @@
+package throttle
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Sampler keeps a fraction of the entries of each log name. Names without
+// a rate of their own use the "*" rate, and are kept in full without one.
+// Counts are kept per configured rate, so names sent by clients can't grow
+// them.
+type Sampler struct {
+	rates map[string]float64
+
+	mu      sync.Mutex
+	kept    map[string]int64
+	dropped map[string]int64
+}
+
+type SampleStats struct {
+	Rate    float64 `json:"rate"`
+	Kept    int64   `json:"kept"`
+	Dropped int64   `json:"dropped"`
+}
+
+func NewSampler(rates map[string]float64) *Sampler {
+	return &Sampler{
+		rates:   rates,
+		kept:    map[string]int64{},
+		dropped: map[string]int64{},
+	}
+}
+
+// ParseRates reads "name=rate" pairs separated by commas, e.g.
+// "debug-trace=0.1,*=0.5", with rates between 0 and 1.
+func ParseRates(raw string) (map[string]float64, error) {
+	rates := map[string]float64{}
+
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid sample rate %q, expected name=rate", pair)
+		}
+
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("invalid sample rate for %s: %s", name, value)
+		}
+
+		rates[strings.TrimSpace(name)] = rate
+	}
+
+	return rates, nil
+}
+
+// rate returns the rate for name and the key it is configured under.
+func (s *Sampler) rate(name string) (float64, string, bool) {
+	if rate, ok := s.rates[name]; ok {
+		return rate, name, true
+	}
+	rate, ok := s.rates["*"]
+
+	return rate, "*", ok
+}
+
+func (s *Sampler) Keep(name string) bool {
+	rate, key, ok := s.rate(name)
+	if !ok {
+		return true
+	}
+
+	keep := rate >= 1 || (rate > 0 && rand.Float64() < rate)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if keep {
+		s.kept[key]++
+	} else {
+		s.dropped[key]++
+	}
+
+	return keep
+}
+
+func (s *Sampler) Stats() map[string]SampleStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make(map[string]SampleStats, len(s.rates))
+	for key, rate := range s.rates {
+		stats[key] = SampleStats{Rate: rate, Kept: s.kept[key], Dropped: s.dropped[key]}
+	}
+
+	return stats
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+var (
+	ErrInvalidAddress = errors.New("invalid address")
+	ErrInvalidHeader  = errors.New("invalid header")
+)
+
+// headers that are set from dedicated fields and cannot be overridden
+var reservedHeaders = map[string]bool{
+	"From":                      true,
+	"Sender":                    true,
+	"To":                        true,
+	"Cc":                        true,
+	"Bcc":                       true,
+	"Reply-To":                  true,
+	"Return-Path":               true,
+	"Subject":                   true,
+	"Date":                      true,
+	"Message-Id":                true,
+	"Mime-Version":              true,
+	"Content-Type":              true,
+	"Content-Transfer-Encoding": true,
+	"List-Unsubscribe":          true,
+	"List-Unsubscribe-Post":     true,
+	"Dkim-Signature":            true,
+}
+
+// AddressList decodes either a JSON array of addresses or a single string,
+// which may hold several comma separated addresses.
+type AddressList []string
+
+func (l *AddressList) UnmarshalJSON(b []byte) error {
+	var list []string
+	if err := json.Unmarshal(b, &list); err == nil {
+		*l = list
+		return nil
+	}
+
+	var single string
+	err := json.Unmarshal(b, &single)
+	if err != nil {
+		return fmt.Errorf("addresses must be a string or a list of strings")
+	}
+
+	*l = nil
+	for _, address := range strings.Split(single, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			*l = append(*l, address)
+		}
+	}
+
+	return nil
+}
+
+// validateAddresses checks every address of a field and reports all the
+// invalid ones at once.
+func validateAddresses(field string, addresses []string) error {
+	var invalid []string
+	for _, address := range addresses {
+		if _, err := mail.ParseAddress(address); err != nil {
+			invalid = append(invalid, fmt.Sprintf("%q", address))
+		}
+	}
+
+	if len(invalid) > 0 {
+		return fmt.Errorf("%w in %s: %s", ErrInvalidAddress, field, strings.Join(invalid, ", "))
+	}
+
+	return nil
+}
+
+func validateHeaders(headers map[string]string) error {
+	for name, value := range headers {
+		canonical := textproto.CanonicalMIMEHeaderKey(name)
+		if reservedHeaders[canonical] {
+			return fmt.Errorf("%w: %s is set by the mail service", ErrInvalidHeader, name)
+		}
+		if name == "" || strings.ContainsFunc(name, func(r rune) bool {
+			return r <= ' ' || r > '~' || r == ':'
+		}) {
+			return fmt.Errorf("%w: bad name %q", ErrInvalidHeader, name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("%w: %s contains a line break", ErrInvalidHeader, name)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the recipients, reply-to and custom headers of a message.
+func (msg Message) Validate() error {
+	if len(msg.To)+len(msg.CC)+len(msg.BCC) == 0 {
+		return fmt.Errorf("%w: no recipients", ErrInvalidAddress)
+	}
+
+	var from []string
+	if msg.From != "" {
+		from = []string{msg.From}
+	}
+
+	err := errors.Join(
+		validateAddresses("from", from),
+		validateAddresses("to", msg.To),
+		validateAddresses("cc", msg.CC),
+		validateAddresses("bcc", msg.BCC),
+		validateHeaders(msg.Headers),
+	)
+	if err != nil {
+		return err
+	}
+
+	if msg.ReplyTo != "" {
+		return validateAddresses("reply_to", []string{msg.ReplyTo})
+	}
+
+	return nil
+}
+
+// Recipients lists every envelope recipient of the message.
+func (msg Message) Recipients() []string {
+	recipients := make([]string, 0, len(msg.To)+len(msg.CC)+len(msg.BCC))
+	recipients = append(recipients, msg.To...)
+	recipients = append(recipients, msg.CC...)
+	recipients = append(recipients, msg.BCC...)
+
+	return recipients
+}
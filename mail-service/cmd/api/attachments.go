@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultMaxAttachmentSize  = 10 << 20
+	defaultMaxAttachmentTotal = 25 << 20
+	defaultAttachmentTypes    = "image/*,application/pdf,text/plain,text/csv,text/calendar,application/json,application/zip,application/msword,application/vnd.ms-excel,application/vnd.openxmlformats-officedocument.*"
+
+	// room for the rest of the message next to the attachments
+	messageOverhead = 1 << 20
+)
+
+var (
+	ErrAttachmentTooLarge = errors.New("attachment too large")
+	ErrAttachmentType     = errors.New("attachment type not allowed")
+	ErrInvalidAttachment  = errors.New("invalid attachment")
+)
+
+// Attachment is a file sent with a message. In JSON the content is base64;
+// multipart uploads are written to a temporary file at Path instead of
+// being held in memory. Inline attachments are referenced from HTML
+// templates as src="cid:<filename>".
+type Attachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Content     []byte `json:"content"`
+	Inline      bool   `json:"inline"`
+
+	Path string `json:"-"`
+	Size int64  `json:"-"`
+}
+
+// AttachmentLimits bound what a message may carry. MaxTotal also bounds
+// the memory a send takes: the message is composed and DKIM signed as a
+// whole, so it is held in memory with its attachments base64 encoded, about
+// 4/3 of MaxTotal plus messageOverhead, rather than streamed.
+type AttachmentLimits struct {
+	MaxSize  int64
+	MaxTotal int64
+	Types    []string
+}
+
+func attachmentLimits() (AttachmentLimits, error) {
+	limits := AttachmentLimits{
+		MaxSize:  defaultMaxAttachmentSize,
+		MaxTotal: defaultMaxAttachmentTotal,
+		Types:    splitList(envOr("MAIL_ATTACHMENT_TYPES", defaultAttachmentTypes)),
+	}
+
+	var err error
+	if value := os.Getenv("MAIL_ATTACHMENT_MAX_SIZE"); value != "" {
+		limits.MaxSize, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return limits, fmt.Errorf("invalid MAIL_ATTACHMENT_MAX_SIZE: %w", err)
+		}
+	}
+	if value := os.Getenv("MAIL_ATTACHMENT_MAX_TOTAL"); value != "" {
+		limits.MaxTotal, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return limits, fmt.Errorf("invalid MAIL_ATTACHMENT_MAX_TOTAL: %w", err)
+		}
+	}
+
+	return limits, nil
+}
+
+func splitList(raw string) []string {
+	var list []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+func (l AttachmentLimits) allowed(contentType string) bool {
+	for _, pattern := range l.Types {
+		if pattern == "*" || pattern == contentType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check normalises the attachments' names and types and enforces the size
+// and type limits.
+func (l AttachmentLimits) Check(attachments []Attachment) error {
+	var total int64
+
+	for i := range attachments {
+		a := &attachments[i]
+
+		a.Filename = filepath.Base(strings.TrimSpace(a.Filename))
+		if a.Filename == "." || a.Filename == string(filepath.Separator) || strings.ContainsAny(a.Filename, "\"\r\n") {
+			return fmt.Errorf("%w: attachment %d needs a valid filename", ErrInvalidAttachment, i+1)
+		}
+
+		if a.Path == "" {
+			a.Size = int64(len(a.Content))
+		}
+		if a.Size == 0 {
+			return fmt.Errorf("%w: %s is empty", ErrInvalidAttachment, a.Filename)
+		}
+		if a.Size > l.MaxSize {
+			return fmt.Errorf("%w: %s is over %d bytes", ErrAttachmentTooLarge, a.Filename, l.MaxSize)
+		}
+
+		total += a.Size
+		if total > l.MaxTotal {
+			return fmt.Errorf("%w: attachments are over %d bytes in total", ErrAttachmentTooLarge, l.MaxTotal)
+		}
+
+		a.ContentType = attachmentType(a.Filename, a.ContentType, a.Content)
+		if !l.allowed(a.ContentType) {
+			return fmt.Errorf("%w: %s (%s)", ErrAttachmentType, a.Filename, a.ContentType)
+		}
+		if a.Inline && !strings.HasPrefix(a.ContentType, "image/") {
+			return fmt.Errorf("%w: only images can be inline, %s is %s", ErrInvalidAttachment, a.Filename, a.ContentType)
+		}
+	}
+
+	return nil
+}
+
+// attachmentType prefers the declared type, then the file extension, then
+// the sniffed content.
+func attachmentType(filename, declared string, content []byte) string {
+	if mediaType := declaredType(declared); mediaType != "" {
+		return mediaType
+	}
+
+	if byExtension := mime.TypeByExtension(filepath.Ext(filename)); byExtension != "" {
+		mediaType, _, _ := mime.ParseMediaType(byExtension)
+		return mediaType
+	}
+
+	if len(content) > 0 {
+		mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(content))
+		return mediaType
+	}
+
+	return "application/octet-stream"
+}
+
+// declaredType is the media type a client declared, ignoring the generic
+// application/octet-stream most clients send for unknown files.
+func declaredType(declared string) string {
+	mediaType, _, err := mime.ParseMediaType(declared)
+	if err != nil || mediaType == "application/octet-stream" {
+		return ""
+	}
+
+	return mediaType
+}
+
+func attachmentStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrAttachmentTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrAttachmentType):
+		return http.StatusUnsupportedMediaType
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// readMultipartMail reads a multipart/form-data request: the "payload" part
+// holds the JSON message and every file part is an attachment, inline when
+// sent under the "inline" field. Files are streamed to temporary files; the
+// returned cleanup removes them.
+func readMultipartMail(w http.ResponseWriter, r *http.Request, limits AttachmentLimits) (mailMessage, func(), error) {
+	var msg mailMessage
+	var uploads []Attachment
+
+	cleanup := func() {
+		for _, a := range uploads {
+			os.Remove(a.Path)
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, limits.MaxTotal+messageOverhead)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return msg, cleanup, err
+	}
+
+	var total int64
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return msg, cleanup, err
+		}
+
+		if part.FileName() == "" {
+			if part.FormName() == "payload" {
+				err = json.NewDecoder(io.LimitReader(part, messageOverhead)).Decode(&msg)
+				if err != nil {
+					return msg, cleanup, fmt.Errorf("invalid payload: %w", err)
+				}
+			}
+			part.Close()
+			continue
+		}
+
+		upload, err := spoolUpload(part, limits.MaxSize)
+		part.Close()
+		if upload.Path != "" {
+			uploads = append(uploads, upload)
+		}
+		if err != nil {
+			return msg, cleanup, err
+		}
+
+		total += upload.Size
+		if total > limits.MaxTotal {
+			return msg, cleanup, fmt.Errorf("%w: attachments are over %d bytes in total", ErrAttachmentTooLarge, limits.MaxTotal)
+		}
+
+		upload.Inline = part.FormName() == "inline"
+		uploads[len(uploads)-1] = upload
+	}
+
+	msg.Attachments = append(msg.Attachments, uploads...)
+
+	return msg, cleanup, nil
+}
+
+// spoolUpload copies one uploaded file to a temporary file, stopping as
+// soon as it grows past the size limit.
+func spoolUpload(part *multipart.Part, maxSize int64) (Attachment, error) {
+	upload := Attachment{
+		Filename:    part.FileName(),
+		ContentType: part.Header.Get("Content-Type"),
+	}
+
+	file, err := os.CreateTemp("", "mail-attachment-*")
+	if err != nil {
+		return upload, err
+	}
+	defer file.Close()
+	upload.Path = file.Name()
+
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(part, sniff)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return upload, err
+	}
+	sniff = sniff[:n]
+
+	size, err := io.Copy(file, io.LimitReader(io.MultiReader(bytes.NewReader(sniff), part), maxSize+1))
+	if err != nil {
+		return upload, err
+	}
+	upload.Size = size
+
+	if size > maxSize {
+		return upload, fmt.Errorf("%w: %s is over %d bytes", ErrAttachmentTooLarge, upload.Filename, maxSize)
+	}
+
+	if declaredType(upload.ContentType) == "" && mime.TypeByExtension(filepath.Ext(upload.Filename)) == "" {
+		upload.ContentType = http.DetectContentType(sniff)
+	}
+
+	return upload, nil
+}
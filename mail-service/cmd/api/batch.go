@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	goweb "github.com/danilobml/go-webtoolkit"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultBatchConcurrency   = 2
+	defaultBatchMaxRecipients = 1000
+
+	batchesDir = "batches"
+
+	StatusRejected = "rejected"
+)
+
+var ErrBatchNotFound = errors.New("batch not found")
+
+type BatchResult struct {
+	To        []string `json:"to"`
+	MessageID string   `json:"message_id,omitempty"`
+	Status    string   `json:"status"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// Batch is one mail merge: a message per recipient, queued together and
+// sent at most Concurrency at a time.
+type Batch struct {
+	ID          string        `json:"id"`
+	Template    string        `json:"template"`
+	Concurrency int           `json:"concurrency"`
+	CreatedAt   time.Time     `json:"created_at"`
+	Results     []BatchResult `json:"results"`
+}
+
+// EnqueueBatch queues every message that passes check, as check returns it,
+// as part of one batch and records the others as rejected.
+func (q *Queue) EnqueueBatch(template string, concurrency int, messages []Message, check func(Message) (Message, error)) (Batch, error) {
+	id, err := newMessageID()
+	if err != nil {
+		return Batch{}, err
+	}
+
+	if concurrency <= 0 || concurrency > q.batchConcurrency {
+		concurrency = q.batchConcurrency
+	}
+
+	batch := &Batch{
+		ID:          id,
+		Template:    template,
+		Concurrency: concurrency,
+		CreatedAt:   time.Now().UTC(),
+		Results:     make([]BatchResult, len(messages)),
+	}
+
+	for i, msg := range messages {
+		result := BatchResult{To: msg.Recipients()}
+
+		msg, err = check(msg)
+		if err == nil {
+			result.MessageID, err = q.enqueue(msg, id, concurrency)
+		}
+		if err != nil {
+			result.Status = StatusRejected
+			result.Error = err.Error()
+		} else {
+			result.Status = StatusQueued
+		}
+
+		batch.Results[i] = result
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.batches[id] = batch
+
+	return *batch, q.saveBatch(batch)
+}
+
+// Batch returns the batch with the current status of each message.
+func (q *Queue) Batch(id string) (Batch, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stored, ok := q.batches[id]
+	if !ok {
+		return Batch{}, fmt.Errorf("%w: %s", ErrBatchNotFound, id)
+	}
+
+	batch := *stored
+	batch.Results = make([]BatchResult, len(stored.Results))
+	for i, result := range stored.Results {
+		if m, ok := q.messages[result.MessageID]; ok {
+			result.Status = m.Status
+			if n := len(m.Attempts); n > 0 {
+				result.Error = m.Attempts[n-1].Error
+			}
+		}
+		batch.Results[i] = result
+	}
+
+	return batch, nil
+}
+
+func (q *Queue) batchPath(id string) string {
+	return filepath.Join(q.dir, batchesDir, id+".json")
+}
+
+// saveBatch writes the batch; callers hold q.mu.
+func (q *Queue) saveBatch(batch *Batch) error {
+	content, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(q.batchPath(batch.ID), string(content))
+}
+
+func (q *Queue) loadBatches() error {
+	paths, err := filepath.Glob(filepath.Join(q.dir, batchesDir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		var batch Batch
+		err = json.Unmarshal(content, &batch)
+		if err != nil {
+			log.Printf("skipping unreadable batch %s: %v", path, err)
+			continue
+		}
+
+		q.batches[batch.ID] = &batch
+	}
+
+	return nil
+}
+
+// pruneBatches forgets a batch, with its messages, once all of them are
+// finished and the last one finished before the cutoff; callers hold q.mu.
+func (q *Queue) pruneBatches(cutoff time.Time) {
+	for id, batch := range q.batches {
+		if !batch.CreatedAt.Before(cutoff) {
+			continue
+		}
+
+		finished := true
+		for _, result := range batch.Results {
+			m, ok := q.messages[result.MessageID]
+			if ok && (!m.done() || !m.UpdatedAt.Before(cutoff)) {
+				finished = false
+				break
+			}
+		}
+		if !finished {
+			continue
+		}
+
+		for _, result := range batch.Results {
+			if result.MessageID != "" {
+				os.Remove(q.recordPath(result.MessageID))
+				os.RemoveAll(filepath.Join(q.dir, result.MessageID))
+				delete(q.messages, result.MessageID)
+			}
+		}
+		os.Remove(q.batchPath(id))
+		delete(q.batches, id)
+	}
+}
+
+type batchRecipient struct {
+	To   AddressList    `json:"to"`
+	Data map[string]any `json:"data"`
+}
+
+type batchRequest struct {
+	From        string            `json:"from"`
+	ReplyTo     string            `json:"reply_to"`
+	Headers     map[string]string `json:"headers"`
+	Subject     string            `json:"subject"`
+	Template    string            `json:"template"`
+	Data        map[string]any    `json:"data"`
+	Recipients  []batchRecipient  `json:"recipients"`
+	Concurrency int               `json:"concurrency"`
+}
+
+// SendBatch renders the template for every recipient with the shared data
+// overlaid by the recipient's own, and queues one message each.
+func (app *Config) SendBatch(w http.ResponseWriter, r *http.Request) {
+	var requestPayload batchRequest
+
+	err := tools.ReadJSON(w, r, &requestPayload)
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if len(requestPayload.Recipients) == 0 {
+		tools.ErrorJSON(w, fmt.Errorf("%w: batch has no recipients", ErrInvalidAddress), http.StatusBadRequest)
+		return
+	}
+	if len(requestPayload.Recipients) > defaultBatchMaxRecipients {
+		tools.ErrorJSON(w, fmt.Errorf("batch is limited to %d recipients", defaultBatchMaxRecipients), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if !app.Mailer.Templates.Has(requestPayload.Template) {
+		tools.ErrorJSON(w, fmt.Errorf("%w: %s", ErrUnknownTemplate, requestPayload.Template), http.StatusBadRequest)
+		return
+	}
+
+	messages := make([]Message, len(requestPayload.Recipients))
+	for i, recipient := range requestPayload.Recipients {
+		data := make(map[string]any, len(requestPayload.Data)+len(recipient.Data))
+		for key, value := range requestPayload.Data {
+			data[key] = value
+		}
+		for key, value := range recipient.Data {
+			data[key] = value
+		}
+
+		messages[i] = Message{
+			From:     requestPayload.From,
+			To:       recipient.To,
+			ReplyTo:  requestPayload.ReplyTo,
+			Headers:  requestPayload.Headers,
+			Subject:  requestPayload.Subject,
+			Template: requestPayload.Template,
+			DataMap:  data,
+		}
+	}
+
+	check := func(msg Message) (Message, error) {
+		err := msg.Validate()
+		if err != nil {
+			return msg, err
+		}
+
+		return app.Mailer.render(msg)
+	}
+
+	batch, err := app.Queue.EnqueueBatch(requestPayload.Template, requestPayload.Concurrency, messages, check)
+	if err != nil {
+		tools.ErrorJSON(w, errors.New("failed queueing batch:"+err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	queued := 0
+	for _, result := range batch.Results {
+		if result.Status == StatusQueued {
+			queued++
+		}
+	}
+
+	payload := goweb.JsonResponse{
+		Error:   false,
+		Message: fmt.Sprintf("queued %d of %d messages", queued, len(batch.Results)),
+		Data:    batch,
+	}
+
+	tools.WriteJSON(w, http.StatusAccepted, payload)
+}
+
+type batchStatus struct {
+	Batch
+	Total  int            `json:"total"`
+	Counts map[string]int `json:"counts"`
+}
+
+// GetBatch reports the status of every message in a batch.
+func (app *Config) GetBatch(w http.ResponseWriter, r *http.Request) {
+	batch, err := app.Queue.Batch(strings.ToLower(chi.URLParam(r, "id")))
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusNotFound)
+		return
+	}
+
+	counts := make(map[string]int)
+	for _, result := range batch.Results {
+		counts[result.Status]++
+	}
+
+	payload := goweb.JsonResponse{
+		Error: false,
+		Data: batchStatus{
+			Batch:  batch,
+			Total:  len(batch.Results),
+			Counts: counts,
+		},
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
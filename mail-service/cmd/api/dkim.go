@@ -0,0 +1,253 @@
+package main
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	goweb "github.com/danilobml/go-webtoolkit"
+	"github.com/toorop/go-dkim"
+)
+
+const (
+	defaultDKIMHeaders          = "from,to,cc,reply-to,subject,date,message-id,mime-version,content-type,list-unsubscribe,list-unsubscribe-post"
+	defaultDKIMCanonicalization = "relaxed/relaxed"
+)
+
+var ErrDKIMKey = errors.New("invalid DKIM key")
+
+// DKIM signs outgoing mail for a domain with an RSA key. The public key
+// comes from its own file when given, so a mismatched pair shows up in the
+// self-check, and is otherwise derived from the private key.
+type DKIM struct {
+	options   dkim.SigOptions
+	publicKey *rsa.PublicKey
+}
+
+func LoadDKIM(privateKeyFile, publicKeyFile, domain, selector string, headers []string, canonicalization string) (*DKIM, error) {
+	if domain == "" || selector == "" {
+		return nil, errors.New("DKIM signing needs a domain and a selector")
+	}
+
+	privatePEM, err := os.ReadFile(privateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := parsePrivateKey(privatePEM)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey := &privateKey.PublicKey
+	if publicKeyFile != "" {
+		publicPEM, err := os.ReadFile(publicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		publicKey, err = parsePublicKey(publicPEM)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	options := dkim.NewSigOptions()
+	options.PrivateKey = privatePEM
+	options.Domain = domain
+	options.Selector = selector
+	options.Headers = headers
+	options.Canonicalization = canonicalization
+
+	return &DKIM{options: options, publicKey: publicKey}, nil
+}
+
+func parsePrivateKey(content []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("%w: private key is not PEM encoded", ErrDKIMKey)
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDKIMKey, err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: only RSA keys are supported", ErrDKIMKey)
+	}
+
+	return rsaKey, nil
+}
+
+func parsePublicKey(content []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("%w: public key is not PEM encoded", ErrDKIMKey)
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDKIMKey, err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: only RSA keys are supported", ErrDKIMKey)
+	}
+
+	return rsaKey, nil
+}
+
+// Sign prepends a DKIM-Signature header to the message.
+func (d *DKIM) Sign(message string) (string, error) {
+	options := d.options
+	// dkim.Sign lowercases the header list in place
+	options.Headers = append([]string(nil), d.options.Headers...)
+
+	raw := []byte(message)
+	err := dkim.Sign(&raw, options)
+	if err != nil {
+		return "", fmt.Errorf("dkim signing failed: %w", err)
+	}
+
+	return string(raw), nil
+}
+
+// Record is the TXT record to publish at <selector>._domainkey.<domain>.
+func (d *DKIM) Record() string {
+	der, _ := x509.MarshalPKIXPublicKey(d.publicKey)
+
+	return "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der)
+}
+
+func (d *DKIM) recordName() string {
+	return d.options.Selector + "._domainkey." + d.options.Domain
+}
+
+// Verify checks a signed message against the configured public key instead
+// of the one published in DNS.
+func (d *DKIM) Verify(message string) error {
+	lookup := func(name string) ([]string, error) {
+		if name != d.recordName() {
+			return nil, fmt.Errorf("message is signed for %s, not %s", name, d.recordName())
+		}
+		return []string{d.Record()}, nil
+	}
+
+	raw := []byte(message)
+	status, err := dkim.Verify(&raw, dkim.DNSOptLookupTXT(lookup))
+	if err != nil {
+		return err
+	}
+	if status != dkim.SUCCESS {
+		return fmt.Errorf("dkim verification failed with status %d", status)
+	}
+
+	return nil
+}
+
+type dkimCheck struct {
+	Domain     string   `json:"domain"`
+	Selector   string   `json:"selector"`
+	Headers    []string `json:"headers"`
+	RecordName string   `json:"record_name"`
+	Record     string   `json:"record"`
+	Signature  string   `json:"signature"`
+	Valid      bool     `json:"valid"`
+	Error      string   `json:"error,omitempty"`
+	DNSRecord  string   `json:"dns_record,omitempty"`
+	DNSMatches *bool    `json:"dns_matches,omitempty"`
+	DNSError   string   `json:"dns_error,omitempty"`
+}
+
+// CheckDKIM signs a sample message the way real mail is signed and
+// verifies it against the configured public key. With ?dns=true it also
+// compares the record published in DNS.
+func (app *Config) CheckDKIM(w http.ResponseWriter, r *http.Request) {
+	d := app.Mailer.DKIM
+
+	check := dkimCheck{
+		Domain:     d.options.Domain,
+		Selector:   d.options.Selector,
+		Headers:    d.options.Headers,
+		RecordName: d.recordName(),
+		Record:     d.Record(),
+	}
+
+	from := app.Mailer.FromAddress
+	if from == "" {
+		from = "dkim-check@" + d.options.Domain
+	}
+
+	email, err := app.Mailer.compose(Message{
+		From:    from,
+		To:      []string{"dkim-check@" + d.options.Domain},
+		Subject: "DKIM self-check",
+		Data:    "DKIM self-check",
+	})
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	message := email.GetMessage()
+	signed, err := d.Sign(message)
+	if err != nil {
+		check.Error = err.Error()
+	} else {
+		check.Signature = strings.TrimSpace(strings.TrimSuffix(signed, message))
+		err = d.Verify(signed)
+		check.Valid = err == nil
+		if err != nil {
+			check.Error = err.Error()
+		}
+	}
+
+	if r.URL.Query().Get("dns") == "true" {
+		records, err := net.LookupTXT(d.recordName())
+		if err != nil {
+			check.DNSError = err.Error()
+		} else {
+			check.DNSRecord = strings.Join(records, "")
+			matches := publicKeyTag(check.DNSRecord) == publicKeyTag(check.Record)
+			check.DNSMatches = &matches
+		}
+	}
+
+	status := http.StatusOK
+	if !check.Valid {
+		status = http.StatusUnprocessableEntity
+	}
+
+	payload := goweb.JsonResponse{
+		Error: !check.Valid,
+		Data:  check,
+	}
+
+	tools.WriteJSON(w, status, payload)
+}
+
+func publicKeyTag(record string) string {
+	for _, tag := range strings.Split(record, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(tag), "=")
+		if strings.TrimSpace(name) == "p" {
+			return strings.Join(strings.Fields(value), "")
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testMessage = "From: Alice <alice@example.com>\r\n" +
+	"To: bob@example.com\r\n" +
+	"Subject: Quarterly report\r\n" +
+	"Date: Mon, 01 Jan 2024 12:00:00 +0000\r\n" +
+	"Message-ID: <1@example.com>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: text/plain; charset=UTF-8\r\n" +
+	"\r\n" +
+	"The numbers are in.\r\n"
+
+// writeKey stores a new RSA key pair as PKCS#1 private and PKIX public PEM
+// files.
+func writeKey(t *testing.T) (privateFile, publicFile string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	privateFile = filepath.Join(dir, "private.pem")
+	publicFile = filepath.Join(dir, "public.pem")
+
+	private := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(privateFile, private, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(publicFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return privateFile, publicFile
+}
+
+func TestDKIMSignVerify(t *testing.T) {
+	privateFile, publicFile := writeKey(t)
+	_, otherPublicFile := writeKey(t)
+	headers := strings.Split(defaultDKIMHeaders, ",")
+
+	tests := []struct {
+		name             string
+		publicFile       string
+		canonicalization string
+		change           func(signed string) string
+		valid            bool
+	}{
+		{name: "relaxed", canonicalization: "relaxed/relaxed", valid: true},
+		{name: "simple", canonicalization: "simple/simple", valid: true},
+		{name: "matching public key file", publicFile: publicFile, canonicalization: "relaxed/relaxed", valid: true},
+		{
+			name:             "relaxed tolerates folded whitespace",
+			canonicalization: "relaxed/relaxed",
+			change: func(signed string) string {
+				return strings.Replace(signed, "Subject: Quarterly report", "Subject:  Quarterly   report", 1)
+			},
+			valid: true,
+		},
+		{
+			name:             "tampered body",
+			canonicalization: "relaxed/relaxed",
+			change: func(signed string) string {
+				return strings.Replace(signed, "The numbers are in.", "The numbers are out.", 1)
+			},
+		},
+		{
+			name:             "tampered header",
+			canonicalization: "relaxed/relaxed",
+			change: func(signed string) string {
+				return strings.Replace(signed, "Quarterly report", "Invoice", 1)
+			},
+		},
+		{name: "mismatched public key file", publicFile: otherPublicFile, canonicalization: "relaxed/relaxed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := LoadDKIM(privateFile, tt.publicFile, "example.com", "mail", headers, tt.canonicalization)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			signed, err := d.Sign(testMessage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(signed, "DKIM-Signature:") || !strings.HasSuffix(signed, testMessage) {
+				t.Fatalf("signature not prepended:\n%s", signed)
+			}
+
+			if tt.change != nil {
+				signed = tt.change(signed)
+			}
+			err = d.Verify(signed)
+			if (err == nil) != tt.valid {
+				t.Errorf("verify = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestLoadDKIMInvalidKey(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "key.pem")
+	os.WriteFile(notPEM, []byte("not a key"), 0600)
+
+	_, err := LoadDKIM(notPEM, "", "example.com", "mail", nil, defaultDKIMCanonicalization)
+	if !errors.Is(err, ErrDKIMKey) {
+		t.Errorf("err = %v, want %v", err, ErrDKIMKey)
+	}
+
+	privateFile, _ := writeKey(t)
+	_, err = LoadDKIM(privateFile, "", "", "mail", nil, defaultDKIMCanonicalization)
+	if err == nil {
+		t.Error("loaded without a domain")
+	}
+}
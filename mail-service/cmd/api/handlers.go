@@ -2,46 +2,95 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"mime"
 	"net/http"
+	"strings"
 
 	goweb "github.com/danilobml/go-webtoolkit"
 )
 
 var tools goweb.Tools
 
-func (app *Config) SendMail(w http.ResponseWriter, r *http.Request) {
-	type mailMessage struct {
-		From    string `json:"from"`
-		To      string `json:"to"`
-		Subject string `json:"subject"`
-		Message string `json:"message"`
-	}
+type mailMessage struct {
+	From        string            `json:"from"`
+	To          AddressList       `json:"to"`
+	CC          AddressList       `json:"cc"`
+	BCC         AddressList       `json:"bcc"`
+	ReplyTo     string            `json:"reply_to"`
+	Headers     map[string]string `json:"headers"`
+	Subject     string            `json:"subject"`
+	Message     string            `json:"message"`
+	Template    string            `json:"template"`
+	Data        map[string]any    `json:"data"`
+	Attachments []Attachment      `json:"attachments"`
+}
 
+func (app *Config) SendMail(w http.ResponseWriter, r *http.Request) {
 	var requestPayload mailMessage
+	var err error
+
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		var cleanup func()
+		requestPayload, cleanup, err = readMultipartMail(w, r, app.Attachments)
+		defer cleanup()
+	} else {
+		err = tools.ReadJSON(w, r, &requestPayload)
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrAttachmentTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		tools.ErrorJSON(w, errors.New("failed sending mail:"+err.Error()), status)
+		return
+	}
 
-	err := tools.ReadJSON(w, r, &requestPayload)
+	err = app.Attachments.Check(requestPayload.Attachments)
 	if err != nil {
-		tools.ErrorJSON(w, errors.New("failed sending mail:"+err.Error()), http.StatusInternalServerError)
+		tools.ErrorJSON(w, err, attachmentStatus(err))
 		return
 	}
-	
+
 	message := Message{
-		From: requestPayload.From,
-		To: requestPayload.To,
-		Subject: requestPayload.Subject,
-		Data: requestPayload.Message,
+		From:     requestPayload.From,
+		To:       requestPayload.To,
+		CC:       requestPayload.CC,
+		BCC:      requestPayload.BCC,
+		ReplyTo:  requestPayload.ReplyTo,
+		Headers:  requestPayload.Headers,
+		Subject:  requestPayload.Subject,
+		Template: requestPayload.Template,
+		Data:     requestPayload.Message,
+		DataMap:  requestPayload.Data,
+
+		Attachments: requestPayload.Attachments,
+	}
+
+	err = message.Validate()
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if !app.Mailer.Templates.Has(message.Template) {
+		tools.ErrorJSON(w, fmt.Errorf("%w: %s", ErrUnknownTemplate, message.Template), http.StatusBadRequest)
+		return
 	}
-	
 
-	err = app.Mailer.SendSMTPMessage(message)
+	id, err := app.Queue.Enqueue(message)
 	if err != nil {
-		tools.ErrorJSON(w, errors.New("failed sending mail:"+err.Error()), http.StatusInternalServerError)
+		tools.ErrorJSON(w, errors.New("failed queueing mail:"+err.Error()), http.StatusInternalServerError)
 		return
 	}
 
 	payload := goweb.JsonResponse{
-		Error: false,
-		Message: "email sent to: " + requestPayload.To,
+		Error:   false,
+		Message: "email queued for: " + strings.Join(message.Recipients(), ", "),
+		Data: map[string]string{
+			"id":     id,
+			"status": StatusQueued,
+		},
 	}
 
 	tools.WriteJSON(w, http.StatusAccepted, payload)
@@ -1,9 +1,10 @@
 package main
 
 import (
-	"bytes"
-	"html/template"
-	"time"
+	"fmt"
+	"log"
+	netmail "net/mail"
+	"strings"
 
 	"github.com/vanng822/go-premailer/premailer"
 	mail "github.com/xhit/go-simple-mail/v2"
@@ -11,26 +12,42 @@ import (
 
 type Mail struct {
 	Domain      string
-	Host        string
-	Port        int
-	Username    string
-	Password    string
-	Encryption  string
 	FromAddress string
 	FromName    string
+	Templates   *Templates
+	Sender      Sender
+
+	Suppressions *Suppressions
+	Unsubscribe  *UnsubscribeLinks
+	DKIM         *DKIM
 }
 
 type Message struct {
 	From        string
 	FromName    string
-	To          string
+	To          []string
+	CC          []string
+	BCC         []string
+	ReplyTo     string
+	Headers     map[string]string
 	Subject     string
-	Attachments []string
+	Template    string
+	Attachments []Attachment
 	Data        any
 	DataMap     map[string]any
+	Rendered    *Rendered
+}
+
+// Rendered is a message's template output, kept with a message that was
+// rendered when it was queued so it isn't rendered again to be sent.
+type Rendered struct {
+	HTML    string
+	Plain   string
+	Subject string
 }
 
-func (m *Mail) SendSMTPMessage(msg Message) error {
+// SendMessage renders the message and hands it to the configured Sender.
+func (m *Mail) SendMessage(msg Message) error {
 	if msg.From == "" {
 		msg.From = m.FromAddress
 	}
@@ -39,77 +56,195 @@ func (m *Mail) SendSMTPMessage(msg Message) error {
 		msg.FromName = m.FromName
 	}
 
-	data := map[string]any{
-		"message": msg.Data,
+	err := msg.Validate()
+	if err != nil {
+		return err
 	}
 
-	msg.DataMap = data
+	var suppressed, dropped []string
+	msg.To, dropped = m.Suppressions.Filter(msg.To)
+	suppressed = append(suppressed, dropped...)
+	msg.CC, dropped = m.Suppressions.Filter(msg.CC)
+	suppressed = append(suppressed, dropped...)
+	msg.BCC, dropped = m.Suppressions.Filter(msg.BCC)
+	suppressed = append(suppressed, dropped...)
 
-	formattedMessage, err := m.buildHTMLMessage(msg)
-	if err != nil {
-		return err
+	if len(msg.Recipients()) == 0 {
+		return fmt.Errorf("%w: %s", ErrSuppressed, strings.Join(suppressed, ", "))
+	}
+	if len(suppressed) > 0 {
+		log.Printf("not sending to suppressed addresses: %s", strings.Join(suppressed, ", "))
 	}
 
-	plainMessage, err := m.buildPlainMessage(msg)
+	email, err := m.compose(msg)
 	if err != nil {
 		return err
 	}
 
-	server := mail.NewSMTPClient()
-	server.Host = m.Host
-	server.Port = m.Port
-	server.Username = m.Username
-	server.Password = m.Password
-	server.Encryption = m.getEncryption(m.Encryption)
-	server.KeepAlive = false
-	server.ConnectTimeout = time.Second * 10
-	server.SendTimeout = time.Second * 10
+	from := msg.From
+	if address, err := netmail.ParseAddress(msg.From); err == nil {
+		from = address.Address
+	}
+
+	// composing and signing need the whole message, attachments included,
+	// which MAIL_ATTACHMENT_MAX_TOTAL keeps bounded
+	message := email.GetMessage()
+	if m.DKIM != nil {
+		message, err = m.DKIM.Sign(message)
+		if err != nil {
+			return err
+		}
+	}
+
+	envelope := Envelope{
+		From:       from,
+		Recipients: email.GetRecipients(),
+		Message:    message,
+	}
 
-	smtpClient, err := server.Connect()
+	err = m.Sender.Send(envelope)
+	if hardBounce(err) && len(envelope.Recipients) == 1 {
+		_, addErr := m.Suppressions.Add(envelope.Recipients[0], ReasonBounce, err.Error())
+		if addErr != nil {
+			log.Println("failed recording bounce: ", addErr)
+		}
+	}
+
+	return err
+}
+
+func (m *Mail) compose(msg Message) (*mail.Email, error) {
+	unsubscribeURL := m.unsubscribeURL(msg)
+
+	msg, err := m.render(msg)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	formattedMessage, plainMessage := msg.Rendered.HTML, msg.Rendered.Plain
+
+	if msg.Subject == "" {
+		msg.Subject = msg.Rendered.Subject
 	}
 
 	email := mail.NewMSG()
-	email.SetFrom(msg.From).SetSubject(msg.Subject).AddTo(msg.To)
+	email.SetFrom(msg.From).SetSubject(msg.Subject)
 
-	email.SetBody(mail.TextPlain, plainMessage)
-	email.AddAlternative(mail.TextHTML, formattedMessage)
+	if len(msg.To) > 0 {
+		email.AddTo(msg.To...)
+	}
+	if len(msg.CC) > 0 {
+		email.AddCc(msg.CC...)
+	}
+	if len(msg.BCC) > 0 {
+		email.AddBcc(msg.BCC...)
+	}
+	if msg.ReplyTo != "" {
+		email.SetReplyTo(msg.ReplyTo)
+	}
+	for name, value := range msg.Headers {
+		email.AddHeader(name, value)
+	}
+	if unsubscribeURL != "" {
+		email.SetListUnsubscribe("<" + unsubscribeURL + ">")
+		email.AddHeader("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+	}
+
+	if plainMessage != "" {
+		email.SetBody(mail.TextPlain, plainMessage)
+		email.AddAlternative(mail.TextHTML, formattedMessage)
+	} else {
+		email.SetBody(mail.TextHTML, formattedMessage)
+	}
 
-	if len(msg.Attachments) > 0 {
-		for _, attachment := range msg.Attachments {
-			email.AddAttachment(attachment)
+	for _, attachment := range msg.Attachments {
+		file := &mail.File{
+			FilePath: attachment.Path,
+			Name:     attachment.Filename,
+			MimeType: attachment.ContentType,
+			Inline:   attachment.Inline,
 		}
+		if attachment.Path == "" {
+			file.Data = attachment.Content
+		}
+		email.Attach(file)
+	}
+
+	return email, email.GetError()
+}
+
+// unsubscribeURL is the signed link to unsubscribe a single recipient gets,
+// in the template data and the List-Unsubscribe header.
+func (m *Mail) unsubscribeURL(msg Message) string {
+	recipients := msg.Recipients()
+	if m.Unsubscribe == nil || len(recipients) != 1 {
+		return ""
 	}
 
-	err = email.Send(smtpClient)
+	address, err := netmail.ParseAddress(recipients[0])
 	if err != nil {
-		return err
+		return ""
 	}
 
-	return nil
+	return m.Unsubscribe.URL(address.Address)
 }
 
-func (m *Mail) buildHTMLMessage(msg Message) (string, error) {
-	templateToRender := "./templates/mail.html.gohtml"
+// render sets msg.Rendered unless the message was already rendered.
+func (m *Mail) render(msg Message) (Message, error) {
+	if msg.Rendered != nil {
+		return msg, nil
+	}
 
-	t, err := template.New("email-html").ParseFiles(templateToRender)
+	data := msg
+	if unsubscribeURL := m.unsubscribeURL(msg); unsubscribeURL != "" {
+		data.DataMap = make(map[string]any, len(msg.DataMap)+1)
+		for key, value := range msg.DataMap {
+			data.DataMap[key] = value
+		}
+		data.DataMap["unsubscribe_url"] = unsubscribeURL
+	}
+
+	formattedMessage, plainMessage, subject, err := m.buildMessage(data)
 	if err != nil {
-		return "", err
+		return msg, err
 	}
 
-	var tpl bytes.Buffer
-	if err := t.ExecuteTemplate(&tpl, "body", msg.DataMap); err != nil {
-		return "", err
+	msg.Rendered = &Rendered{HTML: formattedMessage, Plain: plainMessage, Subject: subject}
+
+	return msg, nil
+}
+
+// buildMessage renders the message's template with its data map, where
+// "message" defaults to msg.Data, and returns the HTML with inlined CSS,
+// the plain text and the template's subject.
+func (m *Mail) buildMessage(msg Message) (string, string, string, error) {
+	set, err := m.Templates.lookup(msg.Template)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return m.renderTemplate(set, msg)
+}
+
+func (m *Mail) renderTemplate(set *mailTemplate, msg Message) (string, string, string, error) {
+	data := make(map[string]any, len(msg.DataMap)+1)
+	for key, value := range msg.DataMap {
+		data[key] = value
+	}
+	if _, ok := data["message"]; !ok {
+		data["message"] = msg.Data
+	}
+
+	formattedMessage, plainMessage, subject, err := set.render(data)
+	if err != nil {
+		return "", "", "", fmt.Errorf("%w: %w", ErrInvalidTemplate, err)
 	}
 
-	formattedMessage := tpl.String()
 	formattedMessage, err = m.inlineCSS(formattedMessage)
 	if err != nil {
-		return "", err
+		return "", "", "", err
 	}
 
-	return formattedMessage, nil
+	return formattedMessage, plainMessage, subject, nil
 }
 
 func (m *Mail) inlineCSS(msg string) (string, error) {
@@ -131,34 +266,3 @@ func (m *Mail) inlineCSS(msg string) (string, error) {
 
 	return html, nil
 }
-
-func (m *Mail) buildPlainMessage(msg Message) (string, error) {
-	templateToRender := "./templates/mail.plain.gohtml"
-
-	t, err := template.New("email-plain").ParseFiles(templateToRender)
-	if err != nil {
-		return "", err
-	}
-
-	var tpl bytes.Buffer
-	if err := t.ExecuteTemplate(&tpl, "body", msg.DataMap); err != nil {
-		return "", err
-	}
-
-	plainMessage := tpl.String()
-
-	return plainMessage, nil
-}
-
-func (m *Mail) getEncryption(encryption string) mail.Encryption {
-	switch encryption {
-	case "tls":
-		return mail.EncryptionSTARTTLS
-	case "ssl":
-		return mail.EncryptionSSLTLS
-	case "none":
-		return mail.EncryptionNone
-	default:
-		return mail.EncryptionSTARTTLS
-	}
-}
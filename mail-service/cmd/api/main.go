@@ -5,28 +5,102 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
+	"time"
 )
 
 const webPort = "80"
 
 type Config struct {
-	Mailer Mail
+	Mailer      Mail
+	Attachments AttachmentLimits
+	Queue       *Queue
+	Capture     *MemorySender
+	SMTP        *SMTPSender
 }
 
 func main() {
+	mailer, err := createMail()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	templates, err := NewTemplates(envOr("MAIL_TEMPLATES_DIR", defaultTemplatesDir))
+	if err != nil {
+		log.Panic(err)
+	}
+	mailer.Templates = templates
+
+	limits, err := attachmentLimits()
+	if err != nil {
+		log.Panic(err)
+	}
+	// base64 JSON attachments are a third larger than the files
+	tools.MaxJsonSize = int(limits.MaxTotal*4/3 + messageOverhead)
+
+	queue, err := createQueue(&mailer)
+	if err != nil {
+		log.Panic(err)
+	}
+
 	app := Config{
-		Mailer: createMail(),
+		Mailer:      mailer,
+		Attachments: limits,
+		Queue:       queue,
+	}
+
+	switch sender := mailer.Sender.(type) {
+	case *MemorySender:
+		app.Capture = sender
+	case *SMTPSender:
+		app.SMTP = sender
+	}
+
+	go queue.Run()
+
+	go app.reloadTemplatesOnSignal()
+
+	if interval := os.Getenv("MAIL_TEMPLATES_RELOAD"); interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil {
+			log.Panic(err)
+		}
+		go templates.Watch(d)
 	}
 
 	app.serve()
 }
 
+// reloadTemplatesOnSignal reloads the template registry on SIGHUP.
+func (app *Config) reloadTemplatesOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		err := app.Mailer.Templates.Reload()
+		if err != nil {
+			log.Println("keeping previous templates, reload failed: ", err)
+			continue
+		}
+		log.Println("reloaded mail templates")
+	}
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return fallback
+}
+
 func (app *Config) serve() {
 	log.Printf("Starting mail service on port %s\n", webPort)
 
 	s := &http.Server{
-		Addr: fmt.Sprintf(":%s", webPort),
+		Addr:    fmt.Sprintf(":%s", webPort),
 		Handler: app.routes(),
 	}
 
@@ -36,18 +110,143 @@ func (app *Config) serve() {
 	}
 }
 
-func createMail() Mail {
-	port, _ := strconv.Atoi(os.Getenv("MAIL_PORT"))
+func createQueue(mailer *Mail) (*Queue, error) {
+	queue, err := NewQueue(envOr("MAIL_QUEUE_DIR", defaultQueueDir), mailer.SendMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	if value := os.Getenv("MAIL_QUEUE_WORKERS"); value != "" {
+		queue.workers, err = strconv.Atoi(value)
+		if err != nil || queue.workers < 1 {
+			return nil, fmt.Errorf("invalid MAIL_QUEUE_WORKERS: %s", value)
+		}
+	}
+	if value := os.Getenv("MAIL_MAX_ATTEMPTS"); value != "" {
+		queue.maxAttempts, err = strconv.Atoi(value)
+		if err != nil || queue.maxAttempts < 1 {
+			return nil, fmt.Errorf("invalid MAIL_MAX_ATTEMPTS: %s", value)
+		}
+	}
+	if value := os.Getenv("MAIL_RETRY_BACKOFF"); value != "" {
+		queue.backoff, err = time.ParseDuration(value)
+		if err != nil || queue.backoff <= 0 {
+			return nil, fmt.Errorf("invalid MAIL_RETRY_BACKOFF: %s", value)
+		}
+	}
+	if value := os.Getenv("MAIL_BATCH_CONCURRENCY"); value != "" {
+		queue.batchConcurrency, err = strconv.Atoi(value)
+		if err != nil || queue.batchConcurrency < 1 {
+			return nil, fmt.Errorf("invalid MAIL_BATCH_CONCURRENCY: %s", value)
+		}
+	}
+	if value := os.Getenv("MAIL_QUEUE_RETENTION"); value != "" {
+		queue.retention, err = time.ParseDuration(value)
+		if err != nil || queue.retention <= 0 {
+			return nil, fmt.Errorf("invalid MAIL_QUEUE_RETENTION: %s", value)
+		}
+	}
+
+	return queue, nil
+}
+
+func createMail() (Mail, error) {
+	sender, err := createSender(envOr("MAIL_SENDER", "smtp"))
+	if err != nil {
+		return Mail{}, err
+	}
+
+	suppressions, err := NewSuppressions(envOr("MAIL_SUPPRESSIONS_FILE", defaultSuppressionsFile))
+	if err != nil {
+		return Mail{}, err
+	}
+
 	m := Mail{
-		Domain: os.Getenv("MAIL_DOMAIN"),
-		Host: os.Getenv("MAIL_HOST"),
-		Port: port,
-		Username: os.Getenv("MAIL_USERNAME"),
-		Password: os.Getenv("MAIL_PASSWORD"),
-		Encryption: os.Getenv("MAIL_ENCRYPTION"),
-		FromName: os.Getenv("MAIL_FROM_NAME"),
+		Domain:      os.Getenv("MAIL_DOMAIN"),
+		FromName:    os.Getenv("MAIL_FROM_NAME"),
 		FromAddress: os.Getenv("MAIL_FROM_ADDRESS"),
+		Sender:      sender,
+
+		Suppressions: suppressions,
+	}
+
+	if keyFile := os.Getenv("MAIL_DKIM_PRIVATE_KEY_FILE"); keyFile != "" {
+		m.DKIM, err = LoadDKIM(
+			keyFile,
+			os.Getenv("MAIL_DKIM_PUBLIC_KEY_FILE"),
+			envOr("MAIL_DKIM_DOMAIN", m.Domain),
+			os.Getenv("MAIL_DKIM_SELECTOR"),
+			splitList(envOr("MAIL_DKIM_HEADERS", defaultDKIMHeaders)),
+			envOr("MAIL_DKIM_CANONICALIZATION", defaultDKIMCanonicalization),
+		)
+		if err != nil {
+			return Mail{}, err
+		}
+		log.Printf("DKIM signing for %s with selector %s", m.DKIM.options.Domain, m.DKIM.options.Selector)
+	}
+
+	if baseURL := os.Getenv("MAIL_UNSUBSCRIBE_URL"); baseURL != "" {
+		secret := os.Getenv("MAIL_UNSUBSCRIBE_SECRET")
+		if secret == "" {
+			return Mail{}, fmt.Errorf("MAIL_UNSUBSCRIBE_URL needs MAIL_UNSUBSCRIBE_SECRET")
+		}
+		m.Unsubscribe = &UnsubscribeLinks{BaseURL: baseURL, Secret: []byte(secret)}
 	}
 
-	return m
+	return m, nil
+}
+
+func createSMTPSender() (*SMTPSender, error) {
+	port, _ := strconv.Atoi(os.Getenv("MAIL_PORT"))
+	sender := &SMTPSender{
+		Host:       os.Getenv("MAIL_HOST"),
+		Port:       port,
+		Username:   os.Getenv("MAIL_USERNAME"),
+		Password:   os.Getenv("MAIL_PASSWORD"),
+		Encryption: os.Getenv("MAIL_ENCRYPTION"),
+
+		PoolSize:    defaultSMTPPoolSize,
+		IdleTimeout: defaultSMTPIdleTimeout,
+		HealthCheck: defaultSMTPHealthCheck,
+	}
+
+	var err error
+	if value := os.Getenv("MAIL_SMTP_POOL_SIZE"); value != "" {
+		sender.PoolSize, err = strconv.Atoi(value)
+		if err != nil || sender.PoolSize < 1 {
+			return nil, fmt.Errorf("invalid MAIL_SMTP_POOL_SIZE: %s", value)
+		}
+	}
+	if value := os.Getenv("MAIL_SMTP_IDLE_TIMEOUT"); value != "" {
+		sender.IdleTimeout, err = time.ParseDuration(value)
+		if err != nil || sender.IdleTimeout <= 0 {
+			return nil, fmt.Errorf("invalid MAIL_SMTP_IDLE_TIMEOUT: %s", value)
+		}
+	}
+	if value := os.Getenv("MAIL_SMTP_HEALTH_CHECK"); value != "" {
+		sender.HealthCheck, err = time.ParseDuration(value)
+		if err != nil || sender.HealthCheck < 0 {
+			return nil, fmt.Errorf("invalid MAIL_SMTP_HEALTH_CHECK: %s", value)
+		}
+	}
+
+	return sender, nil
+}
+
+func createSender(backend string) (Sender, error) {
+	switch backend {
+	case "smtp":
+		return createSMTPSender()
+	case "maildir":
+		dir := envOr("MAIL_MAILDIR", defaultMaildir)
+		log.Printf("Delivering mail to the maildir at %s", dir)
+
+		return NewMaildirSender(dir)
+	case "memory":
+		log.Println("Capturing mail in memory instead of sending it")
+
+		return NewMemorySender(defaultCaptureLimit), nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_SENDER: %s", backend)
+	}
 }
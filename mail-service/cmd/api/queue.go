@@ -0,0 +1,514 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	goweb "github.com/danilobml/go-webtoolkit"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultQueueDir       = "./queue"
+	defaultQueueWorkers   = 4
+	defaultMaxAttempts    = 5
+	defaultRetryBackoff   = 30 * time.Second
+	defaultQueueRetention = 7 * 24 * time.Hour
+
+	maxRetryBackoff   = time.Hour
+	queuePollInterval = time.Second
+)
+
+const (
+	StatusQueued   = "queued"
+	StatusSending  = "sending"
+	StatusRetrying = "retrying"
+	StatusSent     = "sent"
+	StatusFailed   = "failed"
+)
+
+var ErrMessageNotFound = errors.New("message not found")
+
+type Attempt struct {
+	At    time.Time `json:"at"`
+	Error string    `json:"error,omitempty"`
+}
+
+// QueuedMessage is a message accepted for delivery, stored as <id>.json in
+// the queue directory with its attachments under <id>/.
+type QueuedMessage struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"`
+	Message     Message    `json:"message"`
+	Files       []string   `json:"files,omitempty"`
+	Batch       string     `json:"batch,omitempty"`
+	Concurrency int        `json:"concurrency,omitempty"`
+	Attempts    []Attempt  `json:"attempts"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	NextAttempt *time.Time `json:"next_attempt,omitempty"`
+}
+
+func (m *QueuedMessage) done() bool {
+	return m.Status == StatusSent || m.Status == StatusFailed
+}
+
+func (m *QueuedMessage) due(now time.Time) bool {
+	if m.Status != StatusQueued && m.Status != StatusRetrying {
+		return false
+	}
+
+	return m.NextAttempt == nil || !m.NextAttempt.After(now)
+}
+
+// Queue delivers messages in the background, retrying transient failures
+// with exponential backoff. Every state change is written to disk first, so
+// pending messages survive a restart.
+type Queue struct {
+	dir         string
+	send        func(Message) error
+	workers     int
+	maxAttempts int
+	backoff     time.Duration
+	retention   time.Duration
+
+	batchConcurrency int
+
+	mu       sync.Mutex
+	messages map[string]*QueuedMessage
+	batches  map[string]*Batch
+	work     chan string
+	wake     chan struct{}
+}
+
+func NewQueue(dir string, send func(Message) error) (*Queue, error) {
+	err := os.MkdirAll(filepath.Join(dir, batchesDir), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Queue{
+		dir:         dir,
+		send:        send,
+		workers:     defaultQueueWorkers,
+		maxAttempts: defaultMaxAttempts,
+		backoff:     defaultRetryBackoff,
+		retention:   defaultQueueRetention,
+
+		batchConcurrency: defaultBatchConcurrency,
+
+		messages: make(map[string]*QueuedMessage),
+		batches:  make(map[string]*Batch),
+		work:     make(chan string),
+		wake:     make(chan struct{}, 1),
+	}
+
+	return q, q.load()
+}
+
+func (q *Queue) load() error {
+	paths, err := filepath.Glob(filepath.Join(q.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		var m QueuedMessage
+		err = json.Unmarshal(content, &m)
+		if err != nil {
+			log.Printf("skipping unreadable queued message %s: %v", path, err)
+			continue
+		}
+
+		for i, file := range m.Files {
+			if i < len(m.Message.Attachments) {
+				m.Message.Attachments[i].Path = file
+			}
+		}
+
+		// interrupted while sending, so it may or may not have gone out
+		if m.Status == StatusSending {
+			m.Status = StatusQueued
+		}
+
+		q.messages[m.ID] = &m
+	}
+
+	if len(q.messages) > 0 {
+		log.Printf("loaded %d queued messages from %s", len(q.messages), q.dir)
+	}
+
+	return q.loadBatches()
+}
+
+// Enqueue stores the message with its attachments and returns its ID.
+// Uploaded attachment files are moved into the queue directory.
+func (q *Queue) Enqueue(msg Message) (string, error) {
+	return q.enqueue(msg, "", 0)
+}
+
+func (q *Queue) enqueue(msg Message, batch string, concurrency int) (string, error) {
+	id, err := newMessageID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	m := &QueuedMessage{
+		ID:          id,
+		Status:      StatusQueued,
+		Message:     msg,
+		Batch:       batch,
+		Concurrency: concurrency,
+		Attempts:    []Attempt{},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if len(msg.Attachments) > 0 {
+		m.Message.Attachments, m.Files, err = q.storeAttachments(id, msg.Attachments)
+		if err != nil {
+			os.RemoveAll(filepath.Join(q.dir, id))
+			return "", err
+		}
+	}
+
+	q.mu.Lock()
+	err = q.save(m)
+	if err == nil {
+		q.messages[id] = m
+	}
+	q.mu.Unlock()
+
+	if err != nil {
+		os.RemoveAll(filepath.Join(q.dir, id))
+		return "", err
+	}
+
+	q.notify()
+
+	return id, nil
+}
+
+func (q *Queue) storeAttachments(id string, attachments []Attachment) ([]Attachment, []string, error) {
+	dir := filepath.Join(q.dir, id)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stored := make([]Attachment, len(attachments))
+	files := make([]string, len(attachments))
+	for i, a := range attachments {
+		path := filepath.Join(dir, strconv.Itoa(i))
+		if a.Path != "" {
+			err = moveFile(a.Path, path)
+		} else {
+			err = os.WriteFile(path, a.Content, 0644)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		a.Content = nil
+		a.Path = path
+		stored[i] = a
+		files[i] = path
+	}
+
+	return stored, files, nil
+}
+
+func moveFile(src, dst string) error {
+	if os.Rename(src, dst) == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+// Get returns a copy of the message's delivery record.
+func (q *Queue) Get(id string) (QueuedMessage, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	m, ok := q.messages[id]
+	if !ok {
+		return QueuedMessage{}, fmt.Errorf("%w: %s", ErrMessageNotFound, id)
+	}
+
+	copied := *m
+	copied.Attempts = append([]Attempt{}, m.Attempts...)
+
+	return copied, nil
+}
+
+// Run starts the workers and hands them due messages, oldest first. It
+// blocks forever.
+func (q *Queue) Run() {
+	for range q.workers {
+		go func() {
+			for id := range q.work {
+				q.deliver(id)
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(queuePollInterval)
+	defer ticker.Stop()
+
+	for {
+		q.dispatch()
+		q.prune()
+
+		select {
+		case <-ticker.C:
+		case <-q.wake:
+		}
+	}
+}
+
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch hands due messages to the workers, keeping at most a batch's
+// concurrency of its messages in flight.
+func (q *Queue) dispatch() {
+	now := time.Now()
+
+	q.mu.Lock()
+	var due []*QueuedMessage
+	inFlight := make(map[string]int)
+	for _, m := range q.messages {
+		if m.due(now) {
+			due = append(due, m)
+		}
+		if m.Batch != "" && m.Status == StatusSending {
+			inFlight[m.Batch]++
+		}
+	}
+	q.mu.Unlock()
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].CreatedAt.Before(due[j].CreatedAt)
+	})
+
+	for _, m := range due {
+		if m.Batch != "" {
+			if inFlight[m.Batch] >= m.Concurrency {
+				continue
+			}
+			inFlight[m.Batch]++
+		}
+
+		q.mu.Lock()
+		m.Status = StatusSending
+		m.UpdatedAt = time.Now().UTC()
+		err := q.save(m)
+		q.mu.Unlock()
+		if err != nil {
+			log.Printf("failed saving queued message %s: %v", m.ID, err)
+		}
+
+		q.work <- m.ID
+	}
+}
+
+func (q *Queue) deliver(id string) {
+	q.mu.Lock()
+	m := q.messages[id]
+	msg := m.Message
+	q.mu.Unlock()
+
+	err := q.send(msg)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now().UTC()
+	attempt := Attempt{At: now}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	m.Attempts = append(m.Attempts, attempt)
+	m.UpdatedAt = now
+	m.NextAttempt = nil
+
+	switch {
+	case err == nil:
+		m.Status = StatusSent
+	case permanentError(err) || len(m.Attempts) >= q.maxAttempts:
+		m.Status = StatusFailed
+		log.Printf("giving up on message %s after %d attempts: %v", id, len(m.Attempts), err)
+	default:
+		m.Status = StatusRetrying
+		next := now.Add(q.retryDelay(len(m.Attempts)))
+		m.NextAttempt = &next
+		log.Printf("message %s failed, retrying at %s: %v", id, next.Format(time.RFC3339), err)
+	}
+
+	if m.done() {
+		os.RemoveAll(filepath.Join(q.dir, id))
+	}
+
+	err = q.save(m)
+	if err != nil {
+		log.Printf("failed saving queued message %s: %v", id, err)
+	}
+
+	if m.Batch != "" {
+		q.notify()
+	}
+}
+
+// retryDelay doubles the backoff with every failed attempt, up to an hour.
+func (q *Queue) retryDelay(attempts int) time.Duration {
+	delay := q.backoff
+	for i := 1; i < attempts && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxRetryBackoff)
+}
+
+// permanentError reports whether retrying can't help: the message itself is
+// invalid or the server rejected it with a 5xx reply.
+func permanentError(err error) bool {
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Code >= 500
+	}
+
+	return errors.Is(err, ErrInvalidAddress) ||
+		errors.Is(err, ErrInvalidHeader) ||
+		errors.Is(err, ErrSuppressed) ||
+		errors.Is(err, ErrUnknownTemplate) ||
+		errors.Is(err, ErrInvalidTemplate)
+}
+
+// prune forgets delivered and failed messages once they are older than the
+// retention; messages of a batch go with their batch, unless the batch
+// record was never saved.
+func (q *Queue) prune() {
+	cutoff := time.Now().Add(-q.retention)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for id, m := range q.messages {
+		_, inBatch := q.batches[m.Batch]
+		if !inBatch && m.done() && m.UpdatedAt.Before(cutoff) {
+			os.Remove(q.recordPath(id))
+			os.RemoveAll(filepath.Join(q.dir, id))
+			delete(q.messages, id)
+		}
+	}
+
+	q.pruneBatches(cutoff)
+}
+
+func (q *Queue) recordPath(id string) string {
+	return filepath.Join(q.dir, id+".json")
+}
+
+// save writes the record; callers hold q.mu.
+func (q *Queue) save(m *QueuedMessage) error {
+	content, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(q.recordPath(m.ID), string(content))
+}
+
+func newMessageID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+type messageStatus struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"`
+	Recipients  []string   `json:"recipients"`
+	Subject     string     `json:"subject,omitempty"`
+	Template    string     `json:"template,omitempty"`
+	Batch       string     `json:"batch,omitempty"`
+	Attempts    []Attempt  `json:"attempts"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	NextAttempt *time.Time `json:"next_attempt,omitempty"`
+}
+
+// GetMessage reports a queued message's delivery status and attempts.
+func (app *Config) GetMessage(w http.ResponseWriter, r *http.Request) {
+	m, err := app.Queue.Get(strings.ToLower(chi.URLParam(r, "id")))
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusNotFound)
+		return
+	}
+
+	payload := goweb.JsonResponse{
+		Error: false,
+		Data: messageStatus{
+			ID:          m.ID,
+			Status:      m.Status,
+			Recipients:  m.Message.Recipients(),
+			Subject:     m.Message.Subject,
+			Template:    m.Message.Template,
+			Batch:       m.Batch,
+			Attempts:    m.Attempts,
+			CreatedAt:   m.CreatedAt,
+			UpdatedAt:   m.UpdatedAt,
+			NextAttempt: m.NextAttempt,
+		},
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"net/textproto"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	q := &Queue{backoff: 30 * time.Second}
+
+	tests := map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		3:  2 * time.Minute,
+		5:  8 * time.Minute,
+		8:  time.Hour,
+		50: time.Hour,
+	}
+
+	for attempts, want := range tests {
+		if got := q.retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	temporary := &textproto.Error{Code: 451, Msg: "4.3.0 try again later"}
+	permanent := &textproto.Error{Code: 554, Msg: "5.7.1 rejected"}
+
+	tests := []struct {
+		name     string
+		results  []error
+		statuses []string
+	}{
+		{
+			name:     "sent at once",
+			results:  []error{nil},
+			statuses: []string{StatusSent},
+		},
+		{
+			name:     "sent after temporary failures",
+			results:  []error{temporary, errors.New("connection reset"), nil},
+			statuses: []string{StatusRetrying, StatusRetrying, StatusSent},
+		},
+		{
+			name:     "permanent failure",
+			results:  []error{permanent},
+			statuses: []string{StatusFailed},
+		},
+		{
+			name:     "invalid message",
+			results:  []error{ErrInvalidAddress},
+			statuses: []string{StatusFailed},
+		},
+		{
+			name:     "out of attempts",
+			results:  []error{temporary, temporary, temporary},
+			statuses: []string{StatusRetrying, StatusRetrying, StatusFailed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent int
+			q, err := NewQueue(t.TempDir(), func(Message) error {
+				err := tt.results[sent]
+				sent++
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			q.maxAttempts = 3
+
+			id, err := q.Enqueue(Message{To: []string{"bob@example.com"}, Subject: "hi"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, want := range tt.statuses {
+				before := time.Now()
+				q.deliver(id)
+
+				m, err := q.Get(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if m.Status != want {
+					t.Fatalf("attempt %d: status = %s, want %s", i+1, m.Status, want)
+				}
+				if len(m.Attempts) != i+1 {
+					t.Errorf("attempt %d: %d attempts recorded", i+1, len(m.Attempts))
+				}
+
+				if want != StatusRetrying {
+					if m.NextAttempt != nil {
+						t.Errorf("attempt %d: next attempt set on a %s message", i+1, want)
+					}
+					continue
+				}
+				if m.NextAttempt == nil || m.NextAttempt.Before(before.Add(q.retryDelay(i+1))) {
+					t.Errorf("attempt %d: next attempt %v is sooner than the backoff", i+1, m.NextAttempt)
+				}
+				if m.due(time.Now()) {
+					t.Errorf("attempt %d: due before the backoff", i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestQueueRecoversAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	q, err := NewQueue(dir, func(Message) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := q.Enqueue(Message{
+		To:          []string{"bob@example.com"},
+		Subject:     "report",
+		Attachments: []Attachment{{Filename: "report.txt", Content: []byte("numbers")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// interrupted in the middle of a send
+	sending, err := q.Enqueue(Message{To: []string{"alice@example.com"}, Subject: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.mu.Lock()
+	q.messages[sending].Status = StatusSending
+	err = q.save(q.messages[sending])
+	q.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent, err := q.Enqueue(Message{To: []string{"carol@example.com"}, Subject: "done"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.deliver(sent)
+
+	restarted, err := NewQueue(dir, func(Message) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		pending: StatusQueued,
+		sending: StatusQueued,
+		sent:    StatusSent,
+	}
+	for id, want := range tests {
+		m, err := restarted.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Status != want {
+			t.Errorf("%s: status = %s, want %s", m.Message.Subject, m.Status, want)
+		}
+	}
+
+	m, _ := restarted.Get(pending)
+	if len(m.Message.Attachments) != 1 {
+		t.Fatalf("attachments = %+v", m.Message.Attachments)
+	}
+	content, err := os.ReadFile(m.Message.Attachments[0].Path)
+	if err != nil || string(content) != "numbers" {
+		t.Errorf("attachment = %q, %v", content, err)
+	}
+}
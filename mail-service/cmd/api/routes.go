@@ -20,6 +20,38 @@ func (app *Config) routes() http.Handler {
 	}))
 
 	mux.Post("/send", app.SendMail)
+	mux.Post("/send/batch", app.SendBatch)
+	mux.Get("/messages/{id}", app.GetMessage)
+	mux.Get("/batches/{id}", app.GetBatch)
+
+	mux.Get("/suppressions", app.ListSuppressions)
+	mux.Post("/suppressions", app.AddSuppression)
+	mux.Get("/suppressions/{address}", app.GetSuppression)
+	mux.Delete("/suppressions/{address}", app.RemoveSuppression)
+
+	if app.Mailer.Unsubscribe != nil {
+		mux.Get("/unsubscribe", app.Unsubscribe)
+		mux.Post("/unsubscribe", app.Unsubscribe)
+	}
+
+	if app.Mailer.DKIM != nil {
+		mux.Get("/dkim/check", app.CheckDKIM)
+	}
+
+	if app.SMTP != nil {
+		mux.Get("/metrics/smtp", app.SMTPMetrics)
+	}
+
+	if app.Capture != nil {
+		mux.Get("/sent", app.ListSent)
+		mux.Get("/sent/{id}", app.GetSent)
+		mux.Delete("/sent", app.ClearSent)
+	}
+
+	mux.Get("/templates", app.ListTemplates)
+	mux.Post("/templates/reload", app.ReloadTemplates)
+	mux.Put("/templates/{name}", app.UploadTemplate)
+	mux.Post("/templates/{name}/preview", app.PreviewTemplate)
 
 	return mux
 }
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	netmail "net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	goweb "github.com/danilobml/go-webtoolkit"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultMaildir      = "./maildir"
+	defaultCaptureLimit = 1000
+)
+
+// ErrRecipientRejected wraps the server's reply to RCPT TO when it refuses
+// a recipient.
+var ErrRecipientRejected = errors.New("recipient rejected")
+
+// Envelope is a composed RFC 5322 message with the addresses it is
+// delivered to, which include Bcc recipients absent from the headers. The
+// message is held whole; its size is bounded by the attachment limits.
+type Envelope struct {
+	From       string
+	Recipients []string
+	Message    string
+}
+
+// Sender delivers composed messages, chosen with MAIL_SENDER.
+type Sender interface {
+	Send(envelope Envelope) error
+}
+
+// SMTPSender delivers over a pool of persistent SMTP connections.
+type SMTPSender struct {
+	Host       string
+	Port       int
+	Username   string
+	Password   string
+	Encryption string
+
+	PoolSize    int
+	IdleTimeout time.Duration
+	HealthCheck time.Duration
+
+	once sync.Once
+	pool *smtpPool
+}
+
+func (s *SMTPSender) Pool() *smtpPool {
+	s.once.Do(func() {
+		s.pool = newSMTPPool(s.dial, s.PoolSize, s.IdleTimeout, s.HealthCheck)
+		go s.pool.run()
+	})
+
+	return s.pool
+}
+
+// Send retries once on a fresh connection when a pooled one turns out to
+// have been dropped by the server before the message data was sent; after
+// that the server may have accepted the message.
+func (s *SMTPSender) Send(envelope Envelope) error {
+	pool := s.Pool()
+
+	for retried := false; ; retried = true {
+		conn, reused, err := pool.get()
+		if err != nil {
+			return err
+		}
+
+		var dataSent bool
+		err = conn.exchange(func() error {
+			var err error
+			dataSent, err = deliver(conn.client, envelope)
+			return err
+		})
+		pool.put(conn, err)
+
+		if err == nil || !reused || retried || dataSent || !connectionError(err) {
+			return err
+		}
+		pool.reconnected()
+	}
+}
+
+// deliver runs one mail transaction and reports whether it got as far as
+// sending the message data. A rejected recipient is reported as
+// ErrRecipientRejected, which is what a bounce is recorded for.
+func deliver(client *smtp.Client, envelope Envelope) (bool, error) {
+	err := client.Mail(envelope.From)
+	if err != nil {
+		return false, err
+	}
+
+	for _, recipient := range envelope.Recipients {
+		err = client.Rcpt(recipient)
+		var smtpErr *textproto.Error
+		if errors.As(err, &smtpErr) {
+			return false, fmt.Errorf("%w: %w", ErrRecipientRejected, err)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return false, err
+	}
+
+	_, err = io.WriteString(w, envelope.Message)
+	if err != nil {
+		return true, err
+	}
+
+	return true, w.Close()
+}
+
+// dial opens the connection, with TLS from the start for "ssl" and after
+// STARTTLS, when the server offers it, for anything but "none", and logs in
+// when a username is set.
+func (s *SMTPSender) dial() (*pooledConn, error) {
+	address := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+
+	raw, err := net.DialTimeout("tcp", address, smtpTimeout)
+	if err != nil {
+		return nil, err
+	}
+	raw.SetDeadline(time.Now().Add(smtpTimeout))
+
+	client, err := s.handshake(raw)
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+	raw.SetDeadline(time.Time{})
+
+	return &pooledConn{client: client, conn: raw}, nil
+}
+
+func (s *SMTPSender) handshake(raw net.Conn) (*smtp.Client, error) {
+	conn := raw
+	if s.Encryption == "ssl" {
+		conn = tls.Client(raw, &tls.Config{ServerName: s.Host})
+	}
+
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Encryption != "ssl" && s.Encryption != "none" {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			err = client.StartTLS(&tls.Config{ServerName: s.Host})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if s.Username == "" {
+		return client, nil
+	}
+	ok, mechanisms := client.Extension("AUTH")
+	if !ok {
+		return client, nil
+	}
+
+	auth, err := smtpAuth(mechanisms, s.Host, s.Username, s.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	return client, client.Auth(auth)
+}
+
+// MaildirSender drops every message into the new/ folder of a Maildir,
+// readable by mail clients or plain file tools.
+type MaildirSender struct {
+	Dir string
+
+	mu  sync.Mutex
+	seq int
+}
+
+func NewMaildirSender(dir string) (*MaildirSender, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		err := os.MkdirAll(filepath.Join(dir, sub), 0755)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &MaildirSender{Dir: dir}, nil
+}
+
+func (s *MaildirSender) Send(envelope Envelope) error {
+	s.mu.Lock()
+	s.seq++
+	seq := s.seq
+	s.mu.Unlock()
+
+	now := time.Now()
+	host, _ := os.Hostname()
+	name := fmt.Sprintf("%d.M%dP%dQ%d.%s", now.Unix(), now.Nanosecond()/1000, os.Getpid(), seq, host)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Return-Path: <%s>\r\n", envelope.From)
+	for _, recipient := range envelope.Recipients {
+		fmt.Fprintf(&b, "Delivered-To: %s\r\n", recipient)
+	}
+	b.WriteString(envelope.Message)
+
+	tmpPath := filepath.Join(s.Dir, "tmp", name)
+	err := os.WriteFile(tmpPath, []byte(b.String()), 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, filepath.Join(s.Dir, "new", name))
+}
+
+type CapturedMessage struct {
+	ID         string    `json:"id"`
+	From       string    `json:"from"`
+	Recipients []string  `json:"recipients"`
+	Subject    string    `json:"subject"`
+	SentAt     time.Time `json:"sent_at"`
+	Size       int       `json:"size"`
+	Message    string    `json:"message,omitempty"`
+}
+
+// MemorySender keeps the last messages in memory instead of delivering
+// them, for tests and development; they are inspected under /sent.
+type MemorySender struct {
+	limit int
+
+	mu       sync.Mutex
+	messages []CapturedMessage
+	next     int
+}
+
+func NewMemorySender(limit int) *MemorySender {
+	return &MemorySender{limit: limit}
+}
+
+func (s *MemorySender) Send(envelope Envelope) error {
+	var subject string
+	if parsed, err := netmail.ReadMessage(strings.NewReader(envelope.Message)); err == nil {
+		subject = parsed.Header.Get("Subject")
+		if decoded, err := new(mime.WordDecoder).DecodeHeader(subject); err == nil {
+			subject = decoded
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.next++
+	s.messages = append(s.messages, CapturedMessage{
+		ID:         strconv.Itoa(s.next),
+		From:       envelope.From,
+		Recipients: envelope.Recipients,
+		Subject:    subject,
+		SentAt:     time.Now().UTC(),
+		Size:       len(envelope.Message),
+		Message:    envelope.Message,
+	})
+	if len(s.messages) > s.limit {
+		s.messages = s.messages[len(s.messages)-s.limit:]
+	}
+
+	return nil
+}
+
+// Messages lists the captured messages, newest first, without their
+// bodies. A recipient narrows the list to messages delivered to it.
+func (s *MemorySender) Messages(recipient string) []CapturedMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := []CapturedMessage{}
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		m := s.messages[i]
+		if recipient != "" && !containsFold(m.Recipients, recipient) {
+			continue
+		}
+		m.Message = ""
+		list = append(list, m)
+	}
+
+	return list
+}
+
+func (s *MemorySender) Message(id string) (CapturedMessage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range s.messages {
+		if m.ID == id {
+			return m, true
+		}
+	}
+
+	return CapturedMessage{}, false
+}
+
+func (s *MemorySender) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = nil
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (app *Config) ListSent(w http.ResponseWriter, r *http.Request) {
+	payload := goweb.JsonResponse{
+		Error: false,
+		Data:  app.Capture.Messages(r.URL.Query().Get("to")),
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
+
+// GetSent returns a captured message; ?format=raw returns the message as
+// it would have been delivered.
+func (app *Config) GetSent(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	m, ok := app.Capture.Message(id)
+	if !ok {
+		tools.ErrorJSON(w, fmt.Errorf("%w: %s", ErrMessageNotFound, id), http.StatusNotFound)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "raw" {
+		w.Header().Set("Content-Type", "message/rfc822")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(m.Message))
+		return
+	}
+
+	payload := goweb.JsonResponse{
+		Error: false,
+		Data:  m,
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
+
+func (app *Config) ClearSent(w http.ResponseWriter, r *http.Request) {
+	app.Capture.Clear()
+
+	payload := goweb.JsonResponse{
+		Error:   false,
+		Message: "captured messages cleared",
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
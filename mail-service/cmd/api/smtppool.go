@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"sync"
+	"time"
+
+	goweb "github.com/danilobml/go-webtoolkit"
+)
+
+const (
+	defaultSMTPPoolSize    = 4
+	defaultSMTPIdleTimeout = 30 * time.Second
+	defaultSMTPHealthCheck = 5 * time.Second
+
+	// smtpTimeout bounds connecting and every exchange on a connection
+	smtpTimeout = 10 * time.Second
+)
+
+// pooledConn keeps the socket under the SMTP client, so the pool can put
+// deadlines on it and close it without a QUIT.
+type pooledConn struct {
+	client   *smtp.Client
+	conn     net.Conn
+	lastUsed time.Time
+}
+
+// exchange runs fn with a deadline on the socket, so a server that stops
+// responding fails the call instead of blocking it.
+func (c *pooledConn) exchange(fn func() error) error {
+	c.conn.SetDeadline(time.Now().Add(smtpTimeout))
+	defer c.conn.SetDeadline(time.Time{})
+
+	return fn()
+}
+
+type PoolStats struct {
+	Size              int   `json:"size"`
+	Open              int   `json:"open"`
+	Idle              int   `json:"idle"`
+	InUse             int   `json:"in_use"`
+	Dials             int64 `json:"dials"`
+	DialErrors        int64 `json:"dial_errors"`
+	Reuses            int64 `json:"reuses"`
+	HealthCheckFailed int64 `json:"health_check_failed"`
+	Expired           int64 `json:"expired"`
+	Broken            int64 `json:"broken"`
+	Reconnects        int64 `json:"reconnects"`
+	Waits             int64 `json:"waits"`
+}
+
+// smtpPool keeps up to size connections open between sends. A connection
+// idle longer than the health check interval gets a NOOP before reuse, one
+// idle past the idle timeout is closed, and one that fails mid-send is
+// dropped.
+type smtpPool struct {
+	connect     func() (*pooledConn, error)
+	size        int
+	idleTimeout time.Duration
+	healthCheck time.Duration
+
+	slots chan struct{}
+
+	mu    sync.Mutex
+	idle  []*pooledConn
+	open  int
+	stats PoolStats
+}
+
+func newSMTPPool(connect func() (*pooledConn, error), size int, idleTimeout, healthCheck time.Duration) *smtpPool {
+	return &smtpPool{
+		connect:     connect,
+		size:        size,
+		idleTimeout: idleTimeout,
+		healthCheck: healthCheck,
+		slots:       make(chan struct{}, size),
+	}
+}
+
+// get returns a connection and whether it was reused, blocking while all
+// size connections are busy.
+func (p *smtpPool) get() (*pooledConn, bool, error) {
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		p.mu.Lock()
+		p.stats.Waits++
+		p.mu.Unlock()
+		p.slots <- struct{}{}
+	}
+
+	for {
+		p.mu.Lock()
+		if len(p.idle) == 0 {
+			p.mu.Unlock()
+			break
+		}
+		conn := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.mu.Unlock()
+
+		idleFor := time.Since(conn.lastUsed)
+		if idleFor > p.idleTimeout {
+			p.discard(conn, &p.stats.Expired)
+			continue
+		}
+		if idleFor > p.healthCheck && conn.exchange(conn.client.Noop) != nil {
+			p.discard(conn, &p.stats.HealthCheckFailed)
+			continue
+		}
+
+		p.mu.Lock()
+		p.stats.Reuses++
+		p.mu.Unlock()
+
+		return conn, true, nil
+	}
+
+	conn, err := p.dial()
+	if err != nil {
+		<-p.slots
+		return nil, false, err
+	}
+
+	return conn, false, nil
+}
+
+func (p *smtpPool) dial() (*pooledConn, error) {
+	conn, err := p.connect()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stats.Dials++
+	if err != nil {
+		p.stats.DialErrors++
+		return nil, err
+	}
+	p.open++
+
+	return conn, nil
+}
+
+// put returns a connection after a send. Replies from the server leave the
+// connection usable once the failed transaction is reset; any other error
+// means it is broken.
+func (p *smtpPool) put(conn *pooledConn, sendErr error) {
+	defer func() { <-p.slots }()
+
+	if sendErr != nil && !connectionError(sendErr) {
+		sendErr = conn.exchange(conn.client.Reset)
+	}
+	if connectionError(sendErr) {
+		p.discard(conn, &p.stats.Broken)
+		return
+	}
+
+	conn.lastUsed = time.Now()
+
+	p.mu.Lock()
+	p.idle = append(p.idle, conn)
+	p.mu.Unlock()
+}
+
+func (p *smtpPool) reconnected() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stats.Reconnects++
+}
+
+// connectionError reports whether err broke the connection rather than
+// being a reply from the server.
+func connectionError(err error) bool {
+	var smtpErr *textproto.Error
+
+	return err != nil && !errors.As(err, &smtpErr)
+}
+
+// discard closes the socket without a QUIT, which could block on a
+// connection that is already dead.
+func (p *smtpPool) discard(conn *pooledConn, counter *int64) {
+	conn.conn.Close()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.open--
+	*counter++
+}
+
+// expire closes connections idle past the idle timeout, so the server
+// doesn't have to.
+func (p *smtpPool) expire() {
+	p.mu.Lock()
+	var keep, expired []*pooledConn
+	for _, conn := range p.idle {
+		if time.Since(conn.lastUsed) > p.idleTimeout {
+			expired = append(expired, conn)
+		} else {
+			keep = append(keep, conn)
+		}
+	}
+	p.idle = keep
+	p.mu.Unlock()
+
+	for _, conn := range expired {
+		p.discard(conn, &p.stats.Expired)
+	}
+}
+
+func (p *smtpPool) run() {
+	ticker := time.NewTicker(p.idleTimeout / 2)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		p.expire()
+	}
+}
+
+func (p *smtpPool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := p.stats
+	stats.Size = p.size
+	stats.Open = p.open
+	stats.Idle = len(p.idle)
+	stats.InUse = p.open - len(p.idle)
+
+	return stats
+}
+
+func (app *Config) SMTPMetrics(w http.ResponseWriter, r *http.Request) {
+	payload := goweb.JsonResponse{
+		Error: false,
+		Data:  app.SMTP.Pool().Stats(),
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	goweb "github.com/danilobml/go-webtoolkit"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultSuppressionsFile = "./suppressions.json"
+
+	ReasonManual      = "manual"
+	ReasonUnsubscribe = "unsubscribe"
+	ReasonBounce      = "bounce"
+)
+
+var (
+	ErrSuppressed          = errors.New("all recipients are suppressed")
+	ErrSuppressionNotFound = errors.New("address is not suppressed")
+	ErrInvalidSuppression  = errors.New("invalid suppression")
+	ErrInvalidToken        = errors.New("invalid unsubscribe token")
+)
+
+type Suppression struct {
+	Address   string    `json:"address"`
+	Reason    string    `json:"reason"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Suppressions are addresses mail is never sent to, kept in a JSON file.
+type Suppressions struct {
+	path string
+
+	mu      sync.RWMutex
+	entries map[string]Suppression
+}
+
+func NewSuppressions(path string) (*Suppressions, error) {
+	s := &Suppressions{path: path, entries: make(map[string]Suppression)}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, os.MkdirAll(filepath.Dir(path), 0755)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Suppression
+	err = json.Unmarshal(content, &list)
+	if err != nil {
+		return nil, fmt.Errorf("invalid suppressions file %s: %w", path, err)
+	}
+	for _, entry := range list {
+		s.entries[entry.Address] = entry
+	}
+
+	return s, nil
+}
+
+func normalizeAddress(address string) (string, error) {
+	parsed, err := mail.ParseAddress(strings.TrimSpace(address))
+	if err != nil {
+		return "", fmt.Errorf("%w: %q is not an address", ErrInvalidSuppression, address)
+	}
+
+	return strings.ToLower(parsed.Address), nil
+}
+
+// Add suppresses an address; an existing entry keeps its original date.
+func (s *Suppressions) Add(address, reason, note string) (Suppression, error) {
+	address, err := normalizeAddress(address)
+	if err != nil {
+		return Suppression{}, err
+	}
+
+	switch reason {
+	case "":
+		reason = ReasonManual
+	case ReasonManual, ReasonUnsubscribe, ReasonBounce:
+	default:
+		return Suppression{}, fmt.Errorf("%w: unknown reason %q", ErrInvalidSuppression, reason)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := Suppression{Address: address, Reason: reason, Note: note, CreatedAt: time.Now().UTC()}
+	if existing, ok := s.entries[address]; ok {
+		entry.CreatedAt = existing.CreatedAt
+	}
+	s.entries[address] = entry
+
+	return entry, s.save()
+}
+
+func (s *Suppressions) Remove(address string) error {
+	address, err := normalizeAddress(address)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[address]; !ok {
+		return fmt.Errorf("%w: %s", ErrSuppressionNotFound, address)
+	}
+	delete(s.entries, address)
+
+	return s.save()
+}
+
+func (s *Suppressions) Get(address string) (Suppression, error) {
+	address, err := normalizeAddress(address)
+	if err != nil {
+		return Suppression{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.entries[address]
+	if !ok {
+		return Suppression{}, fmt.Errorf("%w: %s", ErrSuppressionNotFound, address)
+	}
+
+	return entry, nil
+}
+
+// List returns the suppressions, newest first, optionally of one reason.
+func (s *Suppressions) List(reason string) []Suppression {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := []Suppression{}
+	for _, entry := range s.entries {
+		if reason == "" || entry.Reason == reason {
+			list = append(list, entry)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+
+	return list
+}
+
+// Filter drops suppressed addresses from the list and returns the ones it
+// dropped.
+func (s *Suppressions) Filter(addresses []string) ([]string, []string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var kept, dropped []string
+	for _, address := range addresses {
+		normalized, err := normalizeAddress(address)
+		if _, ok := s.entries[normalized]; err == nil && ok {
+			dropped = append(dropped, address)
+			continue
+		}
+		kept = append(kept, address)
+	}
+
+	return kept, dropped
+}
+
+// save writes the file; callers hold s.mu.
+func (s *Suppressions) save() error {
+	list := make([]Suppression, 0, len(s.entries))
+	for _, entry := range s.entries {
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Address < list[j].Address
+	})
+
+	content, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.path, string(content))
+}
+
+// hardBounce reports whether the server rejected the mailbox itself, as
+// opposed to the sender, the message or a temporary condition: a permanent
+// refusal of RCPT TO, or an enhanced status 5.1.x other than the ones about
+// the sender's address (5.1.7 and 5.1.8).
+func hardBounce(err error) bool {
+	var smtpErr *textproto.Error
+	if !errors.As(err, &smtpErr) {
+		return false
+	}
+
+	status, _, _ := strings.Cut(smtpErr.Msg, " ")
+	if strings.HasPrefix(status, "5.1.") {
+		return status != "5.1.7" && status != "5.1.8"
+	}
+
+	return errors.Is(err, ErrRecipientRejected) &&
+		(smtpErr.Code == 550 || smtpErr.Code == 551 || smtpErr.Code == 553)
+}
+
+// UnsubscribeLinks signs per-address unsubscribe links with an HMAC, so the
+// link works without storing anything and can't be forged for another
+// address.
+type UnsubscribeLinks struct {
+	BaseURL string
+	Secret  []byte
+}
+
+func (u *UnsubscribeLinks) sign(address string) string {
+	mac := hmac.New(sha256.New, u.Secret)
+	mac.Write([]byte(address))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (u *UnsubscribeLinks) URL(address string) string {
+	address = strings.ToLower(address)
+	token := base64.RawURLEncoding.EncodeToString([]byte(address)) + "." + u.sign(address)
+
+	return u.BaseURL + "?token=" + url.QueryEscape(token)
+}
+
+func (u *UnsubscribeLinks) Verify(token string) (string, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", ErrInvalidToken
+	}
+
+	address, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(u.sign(string(address)))) {
+		return "", ErrInvalidToken
+	}
+
+	return string(address), nil
+}
+
+type suppressionRequest struct {
+	Address string `json:"address"`
+	Reason  string `json:"reason"`
+	Note    string `json:"note"`
+}
+
+func (app *Config) ListSuppressions(w http.ResponseWriter, r *http.Request) {
+	payload := goweb.JsonResponse{
+		Error: false,
+		Data:  app.Mailer.Suppressions.List(r.URL.Query().Get("reason")),
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
+
+func (app *Config) GetSuppression(w http.ResponseWriter, r *http.Request) {
+	entry, err := app.Mailer.Suppressions.Get(chi.URLParam(r, "address"))
+	if err != nil {
+		tools.ErrorJSON(w, err, suppressionStatus(err))
+		return
+	}
+
+	payload := goweb.JsonResponse{
+		Error: false,
+		Data:  entry,
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
+
+// AddSuppression adds a manual entry, or records a bounce or unsubscribe
+// reported by another system.
+func (app *Config) AddSuppression(w http.ResponseWriter, r *http.Request) {
+	var requestPayload suppressionRequest
+
+	err := tools.ReadJSON(w, r, &requestPayload)
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	entry, err := app.Mailer.Suppressions.Add(requestPayload.Address, requestPayload.Reason, requestPayload.Note)
+	if err != nil {
+		tools.ErrorJSON(w, err, suppressionStatus(err))
+		return
+	}
+
+	payload := goweb.JsonResponse{
+		Error:   false,
+		Message: "suppressed " + entry.Address,
+		Data:    entry,
+	}
+
+	tools.WriteJSON(w, http.StatusCreated, payload)
+}
+
+func (app *Config) RemoveSuppression(w http.ResponseWriter, r *http.Request) {
+	address := chi.URLParam(r, "address")
+
+	err := app.Mailer.Suppressions.Remove(address)
+	if err != nil {
+		tools.ErrorJSON(w, err, suppressionStatus(err))
+		return
+	}
+
+	payload := goweb.JsonResponse{
+		Error:   false,
+		Message: "removed suppression for " + address,
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
+
+func suppressionStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrSuppressionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidSuppression):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="UTF-8" /><title>Unsubscribe</title></head>
+<body>
+{{if .Done}}<p>{{.Address}} has been unsubscribed.</p>
+{{else}}<form method="post">
+    <p>Stop sending mail to {{.Address}}?</p>
+    <input type="hidden" name="token" value="{{.Token}}" />
+    <button type="submit">Unsubscribe</button>
+</form>
+{{end}}</body>
+</html>
+`))
+
+// Unsubscribe handles the links in unsubscribe mail: GET asks for
+// confirmation, so link scanners don't unsubscribe anyone, and POST
+// (including RFC 8058 one-click from mail clients) unsubscribes.
+func (app *Config) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	token := r.FormValue("token")
+
+	address, err := app.Mailer.Unsubscribe.Verify(token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	done := r.Method == http.MethodPost
+	if done {
+		_, err = app.Mailer.Suppressions.Add(address, ReasonUnsubscribe, "")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	unsubscribePage.Execute(w, map[string]any{
+		"Address": address,
+		"Token":   token,
+		"Done":    done,
+	})
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"testing"
+)
+
+func TestHardBounce(t *testing.T) {
+	rcpt := func(code int, msg string) error {
+		return fmt.Errorf("%w: %w", ErrRecipientRejected, &textproto.Error{Code: code, Msg: msg})
+	}
+	reply := func(code int, msg string) error {
+		return &textproto.Error{Code: code, Msg: msg}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"unknown mailbox at RCPT", rcpt(550, "5.1.1 no such user"), true},
+		{"mailbox without enhanced status at RCPT", rcpt(550, "no such user"), true},
+		{"user not local at RCPT", rcpt(551, "user not local"), true},
+		{"bad mailbox name at RCPT", rcpt(553, "mailbox name not allowed"), true},
+		{"policy refusal at RCPT", rcpt(554, "5.7.1 relay denied"), false},
+		{"mailbox full at RCPT", rcpt(552, "5.2.2 mailbox full"), false},
+		{"temporary at RCPT", rcpt(450, "4.2.1 try later"), false},
+		{"unknown mailbox after DATA", reply(550, "5.1.1 no such user"), true},
+		{"bad destination system", reply(554, "5.1.2 no such domain"), true},
+		{"sender rejected at MAIL", reply(550, "5.1.8 bad sender domain"), false},
+		{"bad sender syntax", reply(553, "5.1.7 bad sender address"), false},
+		{"plain 550 after DATA", reply(550, "message refused"), false},
+		{"spam refusal", reply(550, "5.7.1 spam"), false},
+		{"temporary", reply(421, "4.1.1 service unavailable"), false},
+		{"not an SMTP reply", errors.New("connection reset"), false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hardBounce(tt.err); got != tt.want {
+				t.Errorf("hardBounce(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	goweb "github.com/danilobml/go-webtoolkit"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultTemplatesDir = "./templates"
+	defaultTemplate     = "mail"
+	htmlSuffix          = ".html.gohtml"
+	plainSuffix         = ".plain.gohtml"
+)
+
+var (
+	ErrUnknownTemplate = errors.New("unknown template")
+	ErrInvalidTemplate = errors.New("invalid template")
+)
+
+// mailTemplate is one named layout: <name>.html.gohtml and an optional
+// <name>.plain.gohtml, each defining "body" and optionally "subject".
+type mailTemplate struct {
+	html  *htmltemplate.Template
+	plain *texttemplate.Template
+}
+
+// Templates is the registry of named mail templates, parsed once and
+// swapped as a whole on reload so a broken edit never replaces a working set.
+type Templates struct {
+	dir    string
+	saveMu sync.Mutex
+
+	mu      sync.RWMutex
+	sets    map[string]*mailTemplate
+	files   int
+	modTime time.Time
+}
+
+type TemplateInfo struct {
+	Name       string `json:"name"`
+	HTML       bool   `json:"html"`
+	Plain      bool   `json:"plain"`
+	HasSubject bool   `json:"has_subject"`
+}
+
+func NewTemplates(dir string) (*Templates, error) {
+	t := &Templates{dir: dir}
+
+	err := t.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (t *Templates) Reload() error {
+	files, modTime, err := latestModTime(t.dir)
+	if err != nil {
+		return err
+	}
+
+	sets, err := parseTemplates(t.dir)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.sets = sets
+	t.files = files
+	t.modTime = modTime
+
+	return nil
+}
+
+func parseTemplates(dir string) (map[string]*mailTemplate, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.gohtml"))
+	if err != nil {
+		return nil, err
+	}
+
+	sources := map[string]*templateSource{}
+	for _, file := range files {
+		base := filepath.Base(file)
+		name, part := base, ""
+		switch {
+		case strings.HasSuffix(base, htmlSuffix):
+			name, part = strings.TrimSuffix(base, htmlSuffix), "html"
+		case strings.HasSuffix(base, plainSuffix):
+			name, part = strings.TrimSuffix(base, plainSuffix), "plain"
+		default:
+			continue
+		}
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		source, ok := sources[name]
+		if !ok {
+			source = &templateSource{}
+			sources[name] = source
+		}
+		if part == "html" {
+			source.HTML = string(content)
+		} else {
+			source.Plain = string(content)
+		}
+	}
+
+	sets := map[string]*mailTemplate{}
+	for name, source := range sources {
+		set, err := parseTemplate(name, source.HTML, source.Plain)
+		if err != nil {
+			return nil, err
+		}
+		sets[name] = set
+	}
+
+	if _, ok := sets[defaultTemplate]; !ok {
+		return nil, fmt.Errorf("default template %s%s not found in %s", defaultTemplate, htmlSuffix, dir)
+	}
+
+	return sets, nil
+}
+
+// templateSource is the text of a template's HTML and plain parts, as read
+// from disk or uploaded.
+type templateSource struct {
+	HTML  string `json:"html"`
+	Plain string `json:"plain"`
+}
+
+// parseTemplate compiles a template's parts; the HTML part is required and
+// both must define "body".
+func parseTemplate(name, html, plain string) (*mailTemplate, error) {
+	if strings.TrimSpace(html) == "" {
+		return nil, fmt.Errorf("template %s: missing %s%s", name, name, htmlSuffix)
+	}
+
+	set := &mailTemplate{}
+
+	var err error
+	set.html, err = htmltemplate.New(name + htmlSuffix).Parse(html)
+	if err != nil {
+		return nil, fmt.Errorf("template %s: %w", name, err)
+	}
+	if set.html.Lookup("body") == nil {
+		return nil, fmt.Errorf("template %s: %s%s must define \"body\"", name, name, htmlSuffix)
+	}
+
+	if strings.TrimSpace(plain) != "" {
+		set.plain, err = texttemplate.New(name + plainSuffix).Parse(plain)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", name, err)
+		}
+		if set.plain.Lookup("body") == nil {
+			return nil, fmt.Errorf("template %s: %s%s must define \"body\"", name, name, plainSuffix)
+		}
+	}
+
+	return set, nil
+}
+
+func (t *Templates) lookup(name string) (*mailTemplate, error) {
+	if name == "" {
+		name = defaultTemplate
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	set, ok := t.sets[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+
+	return set, nil
+}
+
+func (t *Templates) Has(name string) bool {
+	_, err := t.lookup(name)
+	return err == nil
+}
+
+func (t *Templates) List() []TemplateInfo {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var list []TemplateInfo
+	for name, set := range t.sets {
+		list = append(list, TemplateInfo{
+			Name:       name,
+			HTML:       set.html != nil,
+			Plain:      set.plain != nil,
+			HasSubject: set.html.Lookup("subject") != nil || (set.plain != nil && set.plain.Lookup("subject") != nil),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list
+}
+
+// render executes the template's "body" as HTML and plain text, and
+// its "subject" when defined.
+func (set *mailTemplate) render(data map[string]any) (html, plain, subject string, err error) {
+	var buf bytes.Buffer
+	err = set.html.ExecuteTemplate(&buf, "body", data)
+	if err != nil {
+		return "", "", "", err
+	}
+	html = buf.String()
+
+	if set.plain != nil {
+		buf.Reset()
+		err = set.plain.ExecuteTemplate(&buf, "body", data)
+		if err != nil {
+			return "", "", "", err
+		}
+		plain = buf.String()
+	}
+
+	buf.Reset()
+	switch {
+	case set.plain != nil && set.plain.Lookup("subject") != nil:
+		err = set.plain.ExecuteTemplate(&buf, "subject", data)
+	case set.html.Lookup("subject") != nil:
+		err = set.html.ExecuteTemplate(&buf, "subject", data)
+	}
+	if err != nil {
+		return "", "", "", err
+	}
+	subject = strings.TrimSpace(buf.String())
+
+	return html, plain, subject, nil
+}
+
+// Watch reloads the registry whenever a template file in the directory
+// changes, checking every interval.
+func (t *Templates) Watch(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+
+		files, modTime, err := latestModTime(t.dir)
+		if err != nil {
+			log.Println("error checking templates: ", err)
+			continue
+		}
+
+		t.mu.RLock()
+		changed := files != t.files || modTime.After(t.modTime)
+		t.mu.RUnlock()
+
+		if !changed {
+			continue
+		}
+
+		err = t.Reload()
+		if err != nil {
+			log.Println("keeping previous templates, reload failed: ", err)
+			t.mu.Lock()
+			t.files, t.modTime = files, modTime
+			t.mu.Unlock()
+			continue
+		}
+		log.Println("reloaded mail templates")
+	}
+}
+
+func latestModTime(dir string) (int, time.Time, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.gohtml"))
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	var modTime time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return 0, time.Time{}, err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+
+	return len(files), modTime, nil
+}
+
+func (app *Config) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	payload := goweb.JsonResponse{
+		Error: false,
+		Data:  app.Mailer.Templates.List(),
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
+
+func (app *Config) ReloadTemplates(w http.ResponseWriter, r *http.Request) {
+	err := app.Mailer.Templates.Reload()
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	payload := goweb.JsonResponse{
+		Error:   false,
+		Message: "templates reloaded",
+		Data:    app.Mailer.Templates.List(),
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
+
+var templateName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Save validates an uploaded template and writes it to the templates
+// directory before reloading; an empty plain part removes the plain file.
+func (t *Templates) Save(name string, source templateSource) error {
+	if !templateName.MatchString(name) {
+		return fmt.Errorf("%w: invalid name %q", ErrInvalidTemplate, name)
+	}
+
+	_, err := parseTemplate(name, source.HTML, source.Plain)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidTemplate, err)
+	}
+
+	t.saveMu.Lock()
+	defer t.saveMu.Unlock()
+
+	err = writeFileAtomic(filepath.Join(t.dir, name+htmlSuffix), source.HTML)
+	if err != nil {
+		return err
+	}
+
+	plainPath := filepath.Join(t.dir, name+plainSuffix)
+	if strings.TrimSpace(source.Plain) == "" {
+		err = os.Remove(plainPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	} else {
+		err = writeFileAtomic(plainPath, source.Plain)
+		if err != nil {
+			return err
+		}
+	}
+
+	return t.Reload()
+}
+
+func writeFileAtomic(path, content string) error {
+	tmpPath := path + ".tmp"
+
+	err := os.WriteFile(tmpPath, []byte(content), 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+type previewRequest struct {
+	Message string         `json:"message"`
+	Data    map[string]any `json:"data"`
+	HTML    string         `json:"html"`
+	Plain   string         `json:"plain"`
+}
+
+type previewResponse struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Plain   string `json:"plain"`
+}
+
+// PreviewTemplate renders a template with sample data without sending it.
+// A draft can be previewed by passing its html and plain text in the body;
+// ?format=html returns just the rendered HTML for viewing in a browser.
+func (app *Config) PreviewTemplate(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	var requestPayload previewRequest
+	if r.ContentLength != 0 {
+		err := tools.ReadJSON(w, r, &requestPayload)
+		if err != nil {
+			tools.ErrorJSON(w, err, http.StatusBadRequest)
+			return
+		}
+	}
+
+	var set *mailTemplate
+	var err error
+	if requestPayload.HTML != "" {
+		set, err = parseTemplate(name, requestPayload.HTML, requestPayload.Plain)
+		if err != nil {
+			tools.ErrorJSON(w, err, http.StatusUnprocessableEntity)
+			return
+		}
+	} else {
+		set, err = app.Mailer.Templates.lookup(name)
+		if err != nil {
+			tools.ErrorJSON(w, err, http.StatusNotFound)
+			return
+		}
+	}
+
+	html, plain, subject, err := app.Mailer.renderTemplate(set, Message{
+		Data:    requestPayload.Message,
+		DataMap: requestPayload.Data,
+	})
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "html" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(html))
+		return
+	}
+
+	payload := goweb.JsonResponse{
+		Error: false,
+		Data: previewResponse{
+			Subject: subject,
+			HTML:    html,
+			Plain:   plain,
+		},
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
+
+func (app *Config) UploadTemplate(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	var source templateSource
+	err := tools.ReadJSON(w, r, &source)
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = app.Mailer.Templates.Save(name, source)
+	if errors.Is(err, ErrInvalidTemplate) {
+		tools.ErrorJSON(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		tools.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	payload := goweb.JsonResponse{
+		Error:   false,
+		Message: "template " + name + " saved",
+		Data:    app.Mailer.Templates.List(),
+	}
+
+	tools.WriteJSON(w, http.StatusOK, payload)
+}
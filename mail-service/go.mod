@@ -6,15 +6,16 @@ require (
 	github.com/danilobml/go-webtoolkit v0.0.0-20250720130111-78d613fe1fd0
 	github.com/go-chi/chi/v5 v5.2.2
 	github.com/go-chi/cors v1.2.2
+	github.com/toorop/go-dkim v0.0.0-20201103131630-e1cd1a0a5208
+	github.com/vanng822/go-premailer v1.25.0
+	github.com/xhit/go-simple-mail/v2 v2.16.0
 )
 
 require (
 	github.com/PuerkitoBio/goquery v1.10.3 // indirect
 	github.com/andybalholm/cascadia v1.3.3 // indirect
+	github.com/go-test/deep v1.1.1 // indirect
 	github.com/gorilla/css v1.0.1 // indirect
-	github.com/toorop/go-dkim v0.0.0-20201103131630-e1cd1a0a5208 // indirect
 	github.com/vanng822/css v1.0.1 // indirect
-	github.com/vanng822/go-premailer v1.25.0 // indirect
-	github.com/xhit/go-simple-mail/v2 v2.16.0 // indirect
 	golang.org/x/net v0.41.0 // indirect
 )